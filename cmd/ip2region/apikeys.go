@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnk3x/ip2region/pkg/fileio"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/spf13/cobra"
+)
+
+// 轮询 API Key 文件的间隔
+const apiKeysWatchInterval = 10 * time.Second
+
+func bindAPIKeyFlags(c *cobra.Command) {
+	c.Flags().String("api-keys", "", "API Key 文件(JSON), 设置后请求需要通过 X-API-Key 头或 Authorization: Bearer 头提供 API Key, 文件更新后自动重新加载")
+	c.Flags().Bool("api-keys-optional", false, "允许不提供 API Key 的请求, 提供了 API Key 时仍然校验")
+	c.Flags().Bool("api-keys-query", false, "同时接受 key 查询参数中的 API Key, Key 会出现在访问日志和代理的日志中, 不建议开启")
+}
+
+// apiKey API Key 文件中的一项, 文件内容为 apiKey 数组
+type apiKey struct {
+	Key        string   `json:"key"`
+	Name       string   `json:"name"`        // 名称, 用于日志和指标, 不能重复
+	Rate       float64  `json:"rate"`        // 每秒允许的请求数, 0 表示使用 --rate-limit
+	Burst      int      `json:"burst"`       // 允许的突发请求数, 0 表示 rate 的 2 倍
+	DailyQuota int      `json:"daily_quota"` // 每天(UTC)允许的请求数, 0 表示不限制
+	Endpoints  []string `json:"endpoints"`   // 允许访问的路径或 gRPC 方法(如 /ip2region.v1.Lookup/*), 以 /* 结尾时匹配前缀, * 或为空时允许所有
+	Origins    []string `json:"origins"`     // 允许的 Origin, * 或为空时允许所有来源
+	Disabled   bool     `json:"disabled"`
+}
+
+// allowEndpoint 是否允许访问 path
+func (k *apiKey) allowEndpoint(path string) bool {
+	if len(k.Endpoints) == 0 {
+		return true
+	}
+
+	for _, e := range k.Endpoints {
+		switch {
+		case e == "*", e == path:
+			return true
+		case strings.HasSuffix(e, "/*") && strings.HasPrefix(path, e[:len(e)-1]):
+			return true
+		}
+	}
+	return false
+}
+
+// allowOrigin 是否允许来自 origin 的请求, 没有 Origin 头(非浏览器)的请求总是允许
+func (k *apiKey) allowOrigin(origin string) bool {
+	if origin == "" || len(k.Origins) == 0 {
+		return true
+	}
+
+	for _, o := range k.Origins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// 不需要 API Key 的路径, 探针和有单独认证的 /update
+func apiKeyExempt(path string) bool {
+	return probePaths[path] || path == "/update" || strings.HasPrefix(path, "/update/")
+}
+
+// requestAPIKey 从 X-API-Key 或 Authorization: Bearer 头获取 API Key。
+// key 查询参数需要通过 --api-keys-query 开启, 见 apiKeys.Handler
+func requestAPIKey(h http.Header) string {
+	if key := h.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if scheme, token, ok := strings.Cut(h.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+type apiKeyCtxKey struct{}
+
+// apiKeyFrom 请求使用的 API Key, 没有时返回 nil
+func apiKeyFrom(ctx context.Context) *apiKey {
+	k, _ := ctx.Value(apiKeyCtxKey{}).(*apiKey)
+	return k
+}
+
+// apiKeys 校验请求的 API Key, 统计每个 Key 的每日用量。
+// 用量只保存在内存中, 重启后清零。
+type apiKeys struct {
+	file     string
+	optional bool
+	query    bool // 接受 key 查询参数
+	metrics  *promMetrics
+	limiter  *rateLimiter // 按客户端地址限制校验失败的请求, 防止穷举 Key, 为 nil 时不限制
+
+	mu    sync.RWMutex
+	keys  map[[sha256.Size]byte]*apiKey // 以 Key 的 SHA-256 索引, 查找时不逐字节比较 Key
+	usage map[string]*quotaUsage        // name
+}
+
+type quotaUsage struct {
+	day   string
+	count int
+}
+
+// newAPIKeys 按命令行参数加载 API Key 文件, 未设置时返回 nil
+func newAPIKeys(ctx context.Context, c *cobra.Command, metrics *promMetrics) (ak *apiKeys, err error) {
+	file, _ := c.Flags().GetString("api-keys")
+	optional, _ := c.Flags().GetBool("api-keys-optional")
+	query, _ := c.Flags().GetBool("api-keys-query")
+	if file == "" {
+		if optional || query {
+			return nil, errors.New("--api-keys-optional 和 --api-keys-query 需要同时设置 --api-keys")
+		}
+		return nil, nil
+	}
+
+	ak = &apiKeys{file: file, optional: optional, query: query, metrics: metrics, usage: map[string]*quotaUsage{}}
+	if ak.keys, err = loadAPIKeys(file); err != nil {
+		return nil, err
+	}
+
+	fileio.Watch(ctx, file, apiKeysWatchInterval, ak.reload)
+	return
+}
+
+func loadAPIKeys(file string) (keys map[[sha256.Size]byte]*apiKey, err error) {
+	var data []byte
+	if data, err = os.ReadFile(file); err != nil {
+		return nil, fmt.Errorf("读取 API Key 文件失败: %w", err)
+	}
+
+	var list []*apiKey
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析 API Key 文件失败: %w", err)
+	}
+
+	keys = make(map[[sha256.Size]byte]*apiKey, len(list))
+	names := map[string]bool{}
+	for i, k := range list {
+		switch {
+		case k == nil || k.Key == "":
+			return nil, fmt.Errorf("API Key 文件第 %d 项: 缺少 key", i+1)
+		case k.Name == "":
+			return nil, fmt.Errorf("API Key 文件第 %d 项: 缺少 name", i+1)
+		case names[k.Name]:
+			return nil, fmt.Errorf("API Key 文件第 %d 项: name 重复: %s", i+1, k.Name)
+		case k.Rate < 0 || k.Burst < 0 || k.DailyQuota < 0:
+			return nil, fmt.Errorf("API Key 文件第 %d 项: rate, burst, daily_quota 不能小于 0", i+1)
+		}
+
+		sum := sha256.Sum256([]byte(k.Key))
+		if keys[sum] != nil {
+			return nil, fmt.Errorf("API Key 文件第 %d 项: key 重复", i+1)
+		}
+		names[k.Name] = true
+		keys[sum] = k
+	}
+	return
+}
+
+func (ak *apiKeys) reload() {
+	keys, err := loadAPIKeys(ak.file)
+	if err != nil {
+		slog.Warn("API Key 文件重新加载失败，继续使用旧的配置", "file", ak.file, "err", err)
+		return
+	}
+
+	ak.mu.Lock()
+	ak.keys = keys
+	ak.mu.Unlock()
+	slog.Info("API Key 文件已重新加载", "file", ak.file, "keys", len(keys))
+}
+
+func (ak *apiKeys) lookup(key string) *apiKey {
+	ak.mu.RLock()
+	defer ak.mu.RUnlock()
+	return ak.keys[sha256.Sum256([]byte(key))]
+}
+
+// useQuota 使用 k 当天的一次配额, 返回剩余次数, 配额用完时返回 ok=false
+func (ak *apiKeys) useQuota(k *apiKey, now time.Time) (ok bool, remaining int) {
+	ak.mu.Lock()
+	defer ak.mu.Unlock()
+
+	day := now.UTC().Format(time.DateOnly)
+	u := ak.usage[k.Name]
+	if u == nil || u.day != day {
+		u = &quotaUsage{day: day}
+		ak.usage[k.Name] = u
+	}
+
+	if u.count >= k.DailyQuota {
+		return false, 0
+	}
+	u.count++
+	return true, k.DailyQuota - u.count
+}
+
+func (ak *apiKeys) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKeyExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := requestAPIKey(r.Header)
+		if key == "" && ak.query {
+			key = r.URL.Query().Get("key")
+		}
+
+		k, status, err := ak.authorize(key, r.URL.Path, r.Header.Get("Origin"))
+		if k == nil {
+			if err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// 校验失败的请求不会经过限流中间件, 在这里按客户端地址限流
+			if ak.limiter != nil && !ak.limiter.allow(w, r, nil) {
+				return
+			}
+			if key == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ip2region"`)
+			}
+			webErr(w, r, err, status)
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			ak.metrics.ObserveAPIKey(k.Name, status)
+		}()
+
+		if err != nil {
+			webErr(ww, r, err, status)
+			return
+		}
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, k)))
+	})
+}
+
+// authorize 校验 key 能否访问 path, 返回对应的 API Key, 允许且未提供 Key 时返回 nil。
+// 校验失败时返回错误和 HTTP 状态码, Key 有效但不允许访问时同时返回 Key
+func (ak *apiKeys) authorize(key, path, origin string) (k *apiKey, status int, err error) {
+	if key == "" {
+		if ak.optional {
+			return nil, 0, nil
+		}
+		return nil, http.StatusUnauthorized, errors.New("缺少 API Key")
+	}
+
+	if k = ak.lookup(key); k == nil {
+		return nil, http.StatusUnauthorized, errors.New("无效的 API Key")
+	}
+
+	switch {
+	case k.Disabled:
+		return k, http.StatusForbidden, errors.New("API Key 已停用")
+	case !k.allowEndpoint(path):
+		return k, http.StatusForbidden, errors.New("API Key 不允许访问该接口")
+	case !k.allowOrigin(origin):
+		return k, http.StatusForbidden, errors.New("API Key 不允许来自该来源的请求")
+	}
+	return k, 0, nil
+}
+
+// QuotaHandler 检查 API Key 的每日配额, 放在限流之后, 被限流的请求不占用配额
+func (ak *apiKeys) QuotaHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		k := apiKeyFrom(r.Context())
+		if k == nil || k.DailyQuota <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		ok, remaining := ak.useQuota(k, now)
+
+		h := w.Header()
+		h.Set("X-Quota-Limit", strconv.Itoa(k.DailyQuota))
+		h.Set("X-Quota-Remaining", strconv.Itoa(remaining))
+
+		if !ok {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(quotaReset(now))))
+			webErr(w, r, errQuotaExceeded, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var errQuotaExceeded = errors.New("API Key 今日配额已用完")
+
+// quotaReset 距离配额重置(UTC 零点)的时间
+func quotaReset(now time.Time) time.Duration {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+}
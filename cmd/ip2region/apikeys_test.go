@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestAPIKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"none", http.Header{}, ""},
+		{"x-api-key", http.Header{"X-Api-Key": {"k1"}}, "k1"},
+		{"bearer", http.Header{"Authorization": {"Bearer k2"}}, "k2"},
+		{"bearer lower case", http.Header{"Authorization": {"bearer  k3 "}}, "k3"},
+		{"x-api-key first", http.Header{"X-Api-Key": {"k1"}, "Authorization": {"Bearer k2"}}, "k1"},
+		{"basic", http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}, ""},
+		{"no scheme", http.Header{"Authorization": {"k4"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requestAPIKey(tt.header); got != tt.want {
+				t.Errorf("requestAPIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeysHandler(t *testing.T) {
+	k := &apiKey{Key: "secret", Name: "test"}
+	ak := &apiKeys{
+		metrics: newPromMetrics(),
+		keys:    map[[sha256.Size]byte]*apiKey{sha256.Sum256([]byte(k.Key)): k},
+		usage:   map[string]*quotaUsage{},
+	}
+	h := ak.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   int
+	}{
+		{"x-api-key", "/v1/lookup/1.1.1.1", http.Header{"X-Api-Key": {"secret"}}, http.StatusOK},
+		{"bearer", "/v1/lookup/1.1.1.1", http.Header{"Authorization": {"Bearer secret"}}, http.StatusOK},
+		{"missing", "/v1/lookup/1.1.1.1", http.Header{}, http.StatusUnauthorized},
+		{"invalid", "/v1/lookup/1.1.1.1", http.Header{"X-Api-Key": {"other"}}, http.StatusUnauthorized},
+		// 查询参数中的 Key 会出现在访问日志中, 默认不接受
+		{"query", "/v1/lookup/1.1.1.1?key=secret", http.Header{}, http.StatusUnauthorized},
+		{"exempt", "/healthz", http.Header{}, http.StatusOK},
+		{"exempt readyz", "/readyz", http.Header{}, http.StatusOK},
+		{"exempt legacy health", "/health", http.Header{}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Header = tt.header
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeysHandlerQuery(t *testing.T) {
+	ak := newTestAPIKeys(false, &apiKey{Key: "secret", Name: "test"})
+	ak.query = true
+	h := ak.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   int
+	}{
+		{"query", "/v1/lookup/1.1.1.1?key=secret", http.Header{}, http.StatusOK},
+		{"invalid query", "/v1/lookup/1.1.1.1?key=other", http.Header{}, http.StatusUnauthorized},
+		{"header first", "/v1/lookup/1.1.1.1?key=secret", http.Header{"X-Api-Key": {"other"}}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r.Header = tt.header
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeysHandlerFailedAuthRate(t *testing.T) {
+	ak := newTestAPIKeys(false, &apiKey{Key: "secret", Name: "test"})
+	ak.limiter = &rateLimiter{rate: 0.001, burst: 2, buckets: map[string]*bucket{}}
+	h := ak.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// 校验失败的请求按客户端地址限流, 其他地址和有效的 Key 不受影响
+	tests := []struct {
+		remote string
+		key    string
+		want   int
+	}{
+		{"192.0.2.1:1000", "wrong", http.StatusUnauthorized},
+		{"192.0.2.1:1001", "", http.StatusUnauthorized},
+		{"192.0.2.1:1002", "wrong", http.StatusTooManyRequests},
+		{"192.0.2.2:1000", "wrong", http.StatusUnauthorized},
+		{"192.0.2.1:1003", "secret", http.StatusOK},
+	}
+
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v1/lookup/1.1.1.1", nil)
+		r.RemoteAddr = tt.remote
+		if tt.key != "" {
+			r.Header.Set("X-API-Key", tt.key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("request %d: status = %d, want %d", i, w.Code, tt.want)
+		}
+		if tt.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("request %d: missing Retry-After", i)
+		}
+	}
+}
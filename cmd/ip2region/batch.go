@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/go-chi/render"
+)
+
+// batchItem 批量查询中单个IP的结果, 查询失败时 Err 和 Status 为错误信息和对应的 HTTP 状态码
+type batchItem struct {
+	IP     string            `json:"ip"`
+	Result *ip2region.Result `json:"result,omitempty"`
+	Err    string            `json:"err,omitempty"`
+	Status int               `json:"status,omitempty"`
+}
+
+// batchHandler 批量查询, 请求体为 JSON 字符串数组或每行一个IP的文本, 结果按请求顺序返回。
+// Accept 为 application/x-ndjson 时每查询一个IP输出一行 JSON, 否则返回 JSON 数组。
+// 启用限流时每个IP计为一次请求。流式输出时每写出一批结果就把写超时顺延 writeTimeout, 0 表示不限制。
+func batchHandler(s ip2region.Provider, dbt string, max int, writeTimeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		isJSON := isJSONBatch(r)
+		// 每个IP最多按 64 字节计算, 防止请求体过大
+		body := http.MaxBytesReader(w, r.Body, int64(max)*64+1024)
+
+		ips, err := readBatch(body, isJSON, max)
+		if err != nil {
+			status := http.StatusBadRequest
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) || errors.Is(err, errBatchTooLarge) {
+				status = http.StatusRequestEntityTooLarge
+				err = fmt.Errorf("%w: 最多 %d 个", errBatchTooLarge, max)
+			}
+			webErr(w, r, err, status)
+			return
+		}
+
+		// 限流中间件已按一次请求扣除令牌, 其余按IP数量补扣
+		if ok, wait := chargeRate(r.Context(), len(ips)-1); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+			webErr(w, r, errTooManyRequests, http.StatusTooManyRequests)
+			return
+		}
+
+		langs := negotiateLangs(w, r, dbt)
+		search := func(ip string) (item batchItem) {
+			item.IP = ip
+			result, err := s.Search(r.Context(), ip, langs...)
+			if err != nil {
+				item.Err, item.Status = err.Error(), errStatus(err)
+				return
+			}
+			item.Result = result
+			return
+		}
+
+		if !acceptNDJSON(r) {
+			items := make([]batchItem, 0, len(ips))
+			for _, ip := range ips {
+				items = append(items, search(ip))
+			}
+			render.JSON(w, r, items)
+			return
+		}
+
+		// 服务端的写超时从读完请求头开始计算, 结果较多时会中断流, 改为按批顺延
+		rc := http.NewResponseController(w)
+		extend := func() {
+			if writeTimeout > 0 {
+				rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+			}
+		}
+		extend()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+
+		enc := json.NewEncoder(w)
+		for i, ip := range ips {
+			if r.Context().Err() != nil {
+				return
+			}
+			if err := enc.Encode(search(ip)); err != nil {
+				return
+			}
+			if i%100 == 99 || i == len(ips)-1 {
+				rc.Flush()
+				extend()
+			}
+		}
+	}
+}
+
+var errBatchTooLarge = errors.New("IP数量超过限制")
+
+// readBatch 读取批量查询的IP列表, 文本格式忽略空行和 # 开头的注释行
+func readBatch(body io.Reader, isJSON bool, max int) (ips []string, err error) {
+	if isJSON {
+		if err = json.NewDecoder(body).Decode(&ips); err != nil {
+			return nil, fmt.Errorf("解析请求失败: %w", err)
+		}
+		if len(ips) > max {
+			return nil, errBatchTooLarge
+		}
+		return
+	}
+
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if len(ips) == max {
+			return nil, errBatchTooLarge
+		}
+		ips = append(ips, line)
+	}
+	err = sc.Err()
+	return
+}
+
+// isJSONBatch 请求体是否为 JSON, 未指定 Content-Type 时根据首个非空字符判断
+func isJSONBatch(r *http.Request) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err == nil {
+			return mt == "application/json" || strings.HasSuffix(mt, "+json")
+		}
+	}
+
+	br := bufio.NewReader(r.Body)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, r.Body}
+
+	peek, _ := br.Peek(512)
+	return bytes.HasPrefix(bytes.TrimSpace(peek), []byte("["))
+}
+
+func acceptNDJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, _ := strings.Cut(part, ";")
+		switch strings.TrimSpace(strings.ToLower(mt)) {
+		case "application/x-ndjson", "application/ndjson":
+			return true
+		}
+	}
+	return false
+}
@@ -2,17 +2,29 @@ module github.com/cnk3x/ip2region/cmd/ip2region
 
 go 1.23.1
 
-require github.com/spf13/cobra v1.8.1
+require (
+	github.com/cnk3x/ip2region v0.0.0-00010101000000-000000000000
+	github.com/go-chi/chi/v5 v5.1.0
+	github.com/go-chi/cors v1.2.1
+	github.com/go-chi/render v1.0.3
+	github.com/spf13/cobra v1.8.1
+	google.golang.org/grpc v1.71.1
+)
 
 require (
 	github.com/adrg/xdg v0.5.0 // indirect
 	github.com/ajg/form v1.5.1 // indirect
-	github.com/go-chi/chi/v5 v5.1.0 // indirect
-	github.com/go-chi/cors v1.2.1 // indirect
-	github.com/go-chi/render v1.0.3 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/oschwald/geoip2-golang v1.11.0 // indirect
 	github.com/oschwald/maxminddb-golang v1.13.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	golang.org/x/sys v0.22.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+	google.golang.org/protobuf v1.36.6 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// 使用同一仓库中的 ip2region 模块
+replace github.com/cnk3x/ip2region => ../..
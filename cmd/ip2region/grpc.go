@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/cnk3x/ip2region/providers/rpc"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func bindGRPCFlags(c *cobra.Command) {
+	c.Flags().String("grpc-listen", "", "gRPC 服务监听地址, 如 :3825, 为空时不启动, 与 web 服务使用相同的 TLS 配置, API Key 和限流设置, 按直连地址限流")
+}
+
+// startGRPC 按命令行参数启动 gRPC 服务, 未设置 --grpc-listen 时返回 nil, 服务异常退出时调用 onError。
+// keys 和 limiter 与 web 服务共用, 为 nil 时不校验 API Key 或不限流
+func startGRPC(c *cobra.Command, p ip2region.Provider, tlsConfig *tls.Config, keys *apiKeys, limiter *rateLimiter, onError func(error)) (gs *grpc.Server, err error) {
+	listen, _ := c.Flags().GetString("grpc-listen")
+	if listen == "" {
+		return nil, nil
+	}
+
+	var ln net.Listener
+	if ln, err = net.Listen("tcp", listen); err != nil {
+		return nil, fmt.Errorf("gRPC 监听 %s 失败: %w", listen, err)
+	}
+
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	if keys != nil || limiter != nil {
+		g := &grpcGuard{keys: keys, limiter: limiter}
+		opts = append(opts, grpc.ChainUnaryInterceptor(g.unary), grpc.ChainStreamInterceptor(g.stream))
+	}
+
+	gs = grpc.NewServer(opts...)
+	rpc.Register(gs, p)
+
+	slog.Info("grpc listen", "addr", ln.Addr().String(), "tls", tlsConfig != nil)
+	go func() {
+		if err := gs.Serve(ln); err != nil {
+			onError(fmt.Errorf("gRPC 服务异常退出: %w", err))
+		}
+	}()
+	return
+}
+
+// stopGRPC 等待处理中的请求完成, 超过 timeout 后强制关闭
+func stopGRPC(gs *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		gs.Stop()
+	}
+	slog.Info("gRPC 服务已关闭")
+}
+
+// grpcGuard 对 gRPC 请求执行与 web 服务相同的 API Key 校验, 限流和每日配额。
+// API Key 通过 x-api-key 或 authorization: Bearer 元数据提供, 接口路径为完整的方法名, 如 /ip2region.v1.Lookup/Lookup。
+// 流式请求在开始时校验 API Key 并使用一次配额, 每收到一个请求消息扣除一个令牌。
+type grpcGuard struct {
+	keys    *apiKeys
+	limiter *rateLimiter
+}
+
+func (g *grpcGuard) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	setTrailer := func(md metadata.MD) { grpc.SetTrailer(ctx, md) }
+	k, err := g.authorize(ctx, info.FullMethod, setTrailer)
+	defer func() { g.observe(k, err) }()
+	if err != nil {
+		return
+	}
+
+	if err = g.take(ctx, k, setTrailer); err != nil {
+		return
+	}
+	if err = g.useQuota(k); err != nil {
+		return
+	}
+	return handler(ctx, req)
+}
+
+func (g *grpcGuard) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	k, err := g.authorize(ss.Context(), info.FullMethod, ss.SetTrailer)
+	defer func() { g.observe(k, err) }()
+	if err != nil {
+		return
+	}
+
+	if err = g.useQuota(k); err != nil {
+		return
+	}
+	return handler(srv, &guardedStream{ServerStream: ss, g: g, k: k})
+}
+
+// guardedStream 每收到一个请求消息扣除一个令牌, 令牌不足时结束流
+type guardedStream struct {
+	grpc.ServerStream
+	g *grpcGuard
+	k *apiKey
+}
+
+func (s *guardedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.g.take(s.Context(), s.k, s.SetTrailer)
+}
+
+// authorize 校验请求元数据中的 API Key, 未启用 API Key 或允许且未提供时返回 nil。
+// 校验失败时按客户端地址扣除一个令牌, 防止穷举 Key, 令牌不足时返回限流错误
+func (g *grpcGuard) authorize(ctx context.Context, method string, setTrailer func(metadata.MD)) (k *apiKey, err error) {
+	if g.keys == nil {
+		return nil, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	h := http.Header{}
+	for _, name := range []string{"X-API-Key", "Authorization"} {
+		h[http.CanonicalHeaderKey(name)] = md.Get(name)
+	}
+
+	k, code, err := g.keys.authorize(requestAPIKey(h), method, "")
+	if err != nil {
+		if k == nil {
+			if e := g.take(ctx, nil, setTrailer); e != nil {
+				return nil, e
+			}
+		}
+		return k, status.Error(grpcCode(code), err.Error())
+	}
+	return
+}
+
+// take 从请求的限流桶中取一个令牌, 与 web 服务共用按 API Key 或客户端地址区分的桶,
+// 令牌不足时通过 setTrailer 返回 retry-after(秒)
+func (g *grpcGuard) take(ctx context.Context, k *apiKey, setTrailer func(metadata.MD)) error {
+	if g.limiter == nil {
+		return nil
+	}
+
+	var ip string
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+	}
+
+	key, rate, burst, limited := g.limiter.limit(k, ip)
+	if !limited {
+		return nil
+	}
+
+	if ok, _, wait, _ := g.limiter.take(key, rate, burst, 1, time.Now()); !ok {
+		setTrailer(metadata.Pairs("retry-after", strconv.Itoa(ceilSeconds(wait))))
+		return status.Error(codes.ResourceExhausted, errTooManyRequests.Error())
+	}
+	return nil
+}
+
+// useQuota 使用 API Key 当天的一次配额
+func (g *grpcGuard) useQuota(k *apiKey) error {
+	if k == nil || k.DailyQuota <= 0 {
+		return nil
+	}
+	if ok, _ := g.keys.useQuota(k, time.Now()); !ok {
+		return status.Error(codes.ResourceExhausted, errQuotaExceeded.Error())
+	}
+	return nil
+}
+
+// observe 记录 API Key 的请求, 状态码为 gRPC 状态对应的 HTTP 状态码, 与 web 服务的指标一致
+func (g *grpcGuard) observe(k *apiKey, err error) {
+	if k != nil {
+		g.keys.metrics.ObserveAPIKey(k.Name, httpStatus(status.Code(err)))
+	}
+}
+
+// grpcCode HTTP 状态码对应的 gRPC 状态
+func grpcCode(status int) codes.Code {
+	switch status {
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
+
+// httpStatus gRPC 状态对应的 HTTP 状态码, 与 errStatus 一致
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied, codes.FailedPrecondition:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
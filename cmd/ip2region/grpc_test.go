@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/cnk3x/ip2region/providers/rpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type stubProvider struct{}
+
+func (stubProvider) Search(_ context.Context, ip string, _ ...string) (*ip2region.Result, error) {
+	return &ip2region.Result{IP: ip, Country: ip2region.NewName("中国", "CN", 0)}, nil
+}
+func (stubProvider) Update(context.Context) error   { return nil }
+func (stubProvider) Info() (*ip2region.Info, error) { return &ip2region.Info{Type: "stub"}, nil }
+func (stubProvider) Close() error                   { return nil }
+
+// newGuardedClient 启动使用 grpcGuard 的 gRPC 服务, 返回使用 apiKey 的客户端
+func newGuardedClient(t *testing.T, g *grpcGuard, apiKey string) *rpc.Client {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(g.unary), grpc.ChainStreamInterceptor(g.stream))
+	rpc.Register(s, stubProvider{})
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	dial := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) })
+	c, err := rpc.Dial("passthrough:///bufnet", &rpc.Options{APIKey: apiKey, DialOptions: []grpc.DialOption{dial}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func newTestAPIKeys(optional bool, keys ...*apiKey) *apiKeys {
+	ak := &apiKeys{optional: optional, metrics: newPromMetrics(), keys: map[[sha256.Size]byte]*apiKey{}, usage: map[string]*quotaUsage{}}
+	for _, k := range keys {
+		ak.keys[sha256.Sum256([]byte(k.Key))] = k
+	}
+	return ak
+}
+
+func TestGRPCGuardAPIKeys(t *testing.T) {
+	keys := newTestAPIKeys(false,
+		&apiKey{Key: "all", Name: "all"},
+		&apiKey{Key: "web", Name: "web", Endpoints: []string{"/v1/*"}},
+		&apiKey{Key: "grpc", Name: "grpc", Endpoints: []string{"/ip2region.v1.Lookup/*"}},
+		&apiKey{Key: "off", Name: "off", Disabled: true},
+		&apiKey{Key: "quota", Name: "quota", DailyQuota: 1},
+	)
+	g := &grpcGuard{keys: keys}
+
+	tests := []struct {
+		key   string
+		codes []codes.Code // 连续查询的结果
+	}{
+		{"", []codes.Code{codes.Unauthenticated}},
+		{"bogus", []codes.Code{codes.Unauthenticated}},
+		{"all", []codes.Code{codes.OK, codes.OK}},
+		{"web", []codes.Code{codes.PermissionDenied}},
+		{"grpc", []codes.Code{codes.OK}},
+		{"off", []codes.Code{codes.PermissionDenied}},
+		{"quota", []codes.Code{codes.OK, codes.ResourceExhausted}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			c := newGuardedClient(t, g, tt.key)
+			for i, want := range tt.codes {
+				_, err := c.Search(context.Background(), "1.2.3.4")
+				if got := status.Code(err); got != want {
+					t.Errorf("search %d: code = %v, want %v (%v)", i, got, want, err)
+				}
+			}
+		})
+	}
+
+	if got := keys.metrics.apiKeys[[2]string{"web", "403"}]; got != 1 {
+		t.Errorf("metrics web 403 = %d, want 1", got)
+	}
+	if got := keys.metrics.apiKeys[[2]string{"quota", "429"}]; got != 1 {
+		t.Errorf("metrics quota 429 = %d, want 1", got)
+	}
+}
+
+func TestGRPCGuardRateLimit(t *testing.T) {
+	g := &grpcGuard{limiter: &rateLimiter{rate: 0.001, burst: 2, buckets: map[string]*bucket{}}}
+	c := newGuardedClient(t, g, "")
+
+	for i, want := range []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted} {
+		_, err := c.Search(context.Background(), "1.2.3.4")
+		if got := status.Code(err); got != want {
+			t.Errorf("search %d: code = %v, want %v", i, got, want)
+		}
+	}
+
+	// 流式查询每个IP扣除一个令牌, 与单个查询共用桶
+	g.limiter.buckets = map[string]*bucket{}
+	results, err := c.BatchSearch(context.Background(), []string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "4.4.4.4", "5.5.5.5"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("batch err = %v, want ResourceExhausted", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("got %d results before limit, want 2", len(results))
+	}
+}
+
+func TestGRPCGuardAPIKeyRate(t *testing.T) {
+	keys := newTestAPIKeys(true, &apiKey{Key: "k", Name: "k", Rate: 0.001, Burst: 1})
+	g := &grpcGuard{keys: keys, limiter: &rateLimiter{rate: 0.001, burst: 2, buckets: map[string]*bucket{}}}
+
+	// 使用 API Key 时按 Key 的设置限流
+	c := newGuardedClient(t, g, "k")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i, want := range []codes.Code{codes.OK, codes.ResourceExhausted} {
+		if _, err := c.Search(ctx, "1.2.3.4"); status.Code(err) != want {
+			t.Errorf("search %d: err = %v, want %v", i, err, want)
+		}
+	}
+}
+
+func TestGRPCGuardFailedAuthRate(t *testing.T) {
+	keys := newTestAPIKeys(false, &apiKey{Key: "k", Name: "k"})
+	g := &grpcGuard{keys: keys, limiter: &rateLimiter{rate: 0.001, burst: 2, buckets: map[string]*bucket{}}}
+
+	// 校验失败的请求按客户端地址限流
+	c := newGuardedClient(t, g, "wrong")
+	for i, want := range []codes.Code{codes.Unauthenticated, codes.Unauthenticated, codes.ResourceExhausted} {
+		if _, err := c.Search(context.Background(), "1.2.3.4"); status.Code(err) != want {
+			t.Errorf("search %d: err = %v, want %v", i, err, want)
+		}
+	}
+}
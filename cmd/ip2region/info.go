@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func createInfoCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "info",
+		Short: "查看数据库信息",
+		Args:  cobra.NoArgs,
+		Run: func(c *cobra.Command, args []string) {
+			s, err := createSearcher(c, nil)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return
+			}
+			defer s.Close()
+
+			info, err := s.Info()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return
+			}
+
+			if asJSON, _ := c.Flags().GetBool("json"); asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				enc.Encode(info)
+			} else {
+				fmt.Fprint(os.Stdout, info.String())
+			}
+		},
+	}
+
+	c.Flags().StringP("type", "t", "mmdb", "数据库类型, xdb, mmdb")
+	c.Flags().Bool("json", false, "以JSON格式输出")
+	return c
+}
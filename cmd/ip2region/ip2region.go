@@ -1,22 +1,61 @@
 package main
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/cnk3x/ip2region"
 	"github.com/cnk3x/ip2region/pkg/fileio"
 	"github.com/cnk3x/ip2region/providers/mmdb"
+	"github.com/cnk3x/ip2region/providers/overrides"
 	"github.com/cnk3x/ip2region/providers/xdb"
+	"github.com/spf13/cobra"
 )
 
-func createSearcher(ctx context.Context, dbt string) (ip2region.Provider, error) {
+// createSearcher 按命令行参数打开地址库, metrics 可以为 nil
+func createSearcher(c *cobra.Command, metrics ip2region.Metrics) (p ip2region.Provider, err error) {
+	if p, err = openSearcher(c, metrics); err != nil {
+		return
+	}
+
+	if file, _ := c.Flags().GetString("overrides"); file != "" {
+		var o *overrides.Provider
+		if o, err = overrides.Open(p, file, &overrides.Options{Metrics: metrics}); err != nil {
+			p.Close()
+			return nil, err
+		}
+		p = o
+	}
+	return
+}
+
+func openSearcher(c *cobra.Command, metrics ip2region.Metrics) (ip2region.Provider, error) {
+	dbt, _ := c.Flags().GetString("type")
+	maxAge, _ := c.Flags().GetDuration("max-age")
+	versions, _ := c.Flags().GetInt("keep-versions")
+	watch, _ := c.Flags().GetDuration("watch")
+	offline, _ := c.Flags().GetBool("offline")
+	dict, _ := c.Flags().GetStringSlice("dict")
+
 	switch dbt {
 	case "mmdb":
-		return mmdb.Open(ctx, fileio.DataFile("ip2region.mmdb"), nil)
+		return mmdb.Open(c.Context(), dataFile(c), &mmdb.Options{MaxAge: maxAge, Versions: versions, Watch: watch, Offline: offline, Metrics: metrics})
 	case "xdb":
-		return xdb.Open(ctx, fileio.DataFile("ip2region.xdb"), &xdb.Options{Cache: xdb.Content})
+		return xdb.Open(c.Context(), dataFile(c), &xdb.Options{Cache: xdb.Content, MaxAge: maxAge, Versions: versions, Watch: watch, Offline: offline, Dictionary: dict, Metrics: metrics})
 	default:
 		return nil, fmt.Errorf("不支持的数据库类型: %s", dbt)
 	}
 }
+
+func dataFile(c *cobra.Command) string {
+	dbt, _ := c.Flags().GetString("type")
+	return fileio.DataFile("ip2region." + dbt)
+}
+
+func bindSearcherFlags(root *cobra.Command) {
+	root.PersistentFlags().Duration("max-age", 0, "数据库最长使用时间, 超过后自动更新, 如 720h, 0 表示不限制")
+	root.PersistentFlags().Int("keep-versions", 0, "保留的数据库版本数, 用于回滚, 0 表示直接覆盖, 需要系统支持符号链接")
+	root.PersistentFlags().Bool("offline", false, "离线模式, 不下载数据库")
+	root.PersistentFlags().StringSlice("dict", nil, "xdb 自定义词典文件(csv), 用于翻译查询结果, 可指定多个")
+	root.PersistentFlags().String("overrides", "", "自定义地址段文件, 支持 csv, json, yaml")
+	root.PersistentFlags().Duration("watch", 0, "轮询数据库文件的间隔, 文件被替换后自动重新加载, 如 10s, 0 表示不监听")
+}
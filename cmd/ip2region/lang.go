@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// requestLangs 按优先级返回请求的语言, lang 参数(逗号分隔)优先于 Accept-Language 头
+func requestLangs(r *http.Request) (langs []string) {
+	if v := r.FormValue("lang"); v != "" {
+		for _, tag := range strings.Split(v, ",") {
+			langs = appendLang(langs, tag)
+		}
+		return
+	}
+	return parseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// negotiateLangs 返回请求的语言, 并设置 Content-Language 和 Vary 响应头
+func negotiateLangs(w http.ResponseWriter, r *http.Request, dbt string) (langs []string) {
+	langs = requestLangs(r)
+	w.Header().Add("Vary", "Accept-Language")
+	if lang := contentLang(dbt, langs); lang != "" {
+		w.Header().Set("Content-Language", lang)
+	}
+	return
+}
+
+// parseAcceptLanguage 解析 Accept-Language 头, 按 q 值从高到低排序, q=0 和 * 会被忽略
+func parseAcceptLanguage(header string) (langs []string) {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag = strings.TrimSpace(tag); tag == "" || tag == "*" {
+			continue
+		}
+
+		if q := qValue(params); q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		langs = appendLang(langs, t.tag)
+	}
+	return
+}
+
+// qValue 返回 Accept 类请求头参数中的 q 值, 未指定时为 1
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(strings.TrimSpace(k), "q") {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f
+			}
+		}
+	}
+	return 1
+}
+
+func appendLang(langs []string, tag string) []string {
+	if lang := normalizeLang(tag); lang != "" && !slices.Contains(langs, lang) {
+		langs = append(langs, lang)
+	}
+	return langs
+}
+
+// normalizeLang 将语言标签转换为 mmdb 的语言代码, 如 zh-Hans-CN -> zh-CN, pt -> pt-BR, en-US -> en
+func normalizeLang(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	base, _, _ := strings.Cut(tag, "-")
+	switch base {
+	case "":
+		return ""
+	case "zh":
+		return "zh-CN"
+	case "pt":
+		return "pt-BR"
+	default:
+		return base
+	}
+}
+
+// 各类型数据库内置的语言, 第一个为未指定语言时的默认语言
+var dbLangs = map[string][]string{
+	"xdb":  {"zh-CN", "en"},
+	"mmdb": {"en", "de", "es", "fr", "ja", "pt-BR", "ru", "zh-CN"},
+}
+
+// contentLang 返回查询结果实际使用的语言, 即 langs 中第一个数据库支持的语言
+func contentLang(dbt string, langs []string) string {
+	supported := dbLangs[dbt]
+	for _, lang := range langs {
+		if slices.Contains(supported, lang) {
+			return lang
+		}
+	}
+	if len(supported) > 0 {
+		return supported[0]
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", nil},
+		{"en", []string{"en"}},
+		{"zh-Hans-CN", []string{"zh-CN"}},
+		{"en-US,en;q=0.9,zh-CN;q=0.8", []string{"en", "zh-CN"}},
+		{"fr;q=0.5, de;q=0.9, ja", []string{"ja", "de", "fr"}},
+		{"de;q=0.5, fr;q=0.5", []string{"de", "fr"}},
+		{"pt, pt-PT;q=0.9", []string{"pt-BR"}},
+		{"zh_TW, en ; Q=0.3", []string{"zh-CN", "en"}},
+		{"*, en;q=0", nil},
+		{"ru;q=0, en;q=0.1", []string{"en"}},
+		{"es;q=bad", []string{"es"}},
+		{" , ,fr", []string{"fr"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); !slices.Equal(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLangs(t *testing.T) {
+	tests := []struct {
+		target string
+		header string
+		want   []string
+	}{
+		{"/", "de,en;q=0.5", []string{"de", "en"}},
+		{"/?lang=en,zh-TW,en-GB", "de", []string{"en", "zh-CN"}},
+		{"/?lang=", "ja", []string{"ja"}},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r.Header.Set("Accept-Language", tt.header)
+		if got := requestLangs(r); !slices.Equal(got, tt.want) {
+			t.Errorf("requestLangs(%s, %q) = %q, want %q", tt.target, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestContentLang(t *testing.T) {
+	tests := []struct {
+		dbt   string
+		langs []string
+		want  string
+	}{
+		{"xdb", nil, "zh-CN"},
+		{"xdb", []string{"de", "en"}, "en"},
+		{"mmdb", nil, "en"},
+		{"mmdb", []string{"ko", "ja", "en"}, "ja"},
+		{"mmdb", []string{"ko"}, "en"},
+		{"remote", []string{"en"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := contentLang(tt.dbt, tt.langs); got != tt.want {
+			t.Errorf("contentLang(%s, %q) = %q, want %q", tt.dbt, tt.langs, got, tt.want)
+		}
+	}
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"os"
+
 	"github.com/spf13/cobra"
 )
 
 func main() {
 	root := &cobra.Command{}
 	root.CompletionOptions.HiddenDefaultCmd = true
-	root.AddCommand(createQueryCommand(), createWebCommand(), createUpdateCommand())
+	bindSearcherFlags(root)
+	root.AddCommand(createQueryCommand(), createWebCommand(), createUpdateCommand(), createInfoCommand(), createRollbackCommand())
 	root.InitDefaultHelpCmd()
 	for _, c := range root.Commands() {
 		if c.Name() == "help" {
@@ -15,5 +18,7 @@ func main() {
 		}
 	}
 
-	root.Execute()
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
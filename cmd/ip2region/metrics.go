@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+)
+
+var (
+	lookupBuckets = []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1}
+	updateBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+)
+
+// promMetrics 实现 ip2region.Metrics, 以 Prometheus 文本格式输出
+type promMetrics struct {
+	mu sync.Mutex
+
+	lookups      map[string]*histogram // provider
+	lookupErrors map[[2]string]uint64  // provider, type
+	ioReads      map[string]uint64     // provider
+	cacheHits    map[string]uint64     // provider
+	cacheMisses  map[string]uint64     // provider
+	updates      map[[2]string]uint64  // provider, result
+	updateTimes  map[string]*histogram // provider
+	lastSuccess  map[string]time.Time  // provider
+	apiKeys      map[[2]string]uint64  // key name, code
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{
+		lookups:      map[string]*histogram{},
+		lookupErrors: map[[2]string]uint64{},
+		ioReads:      map[string]uint64{},
+		cacheHits:    map[string]uint64{},
+		cacheMisses:  map[string]uint64{},
+		updates:      map[[2]string]uint64{},
+		updateTimes:  map[string]*histogram{},
+		lastSuccess:  map[string]time.Time{},
+		apiKeys:      map[[2]string]uint64{},
+	}
+}
+
+func (m *promMetrics) ObserveLookup(provider string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.lookups[provider]
+	if h == nil {
+		h = newHistogram(lookupBuckets)
+		m.lookups[provider] = h
+	}
+	h.observe(d.Seconds())
+
+	if err != nil {
+		m.lookupErrors[[2]string{provider, ip2region.ErrorType(err)}]++
+	}
+}
+
+func (m *promMetrics) ObserveIO(provider string, reads int) {
+	m.mu.Lock()
+	m.ioReads[provider] += uint64(reads)
+	m.mu.Unlock()
+}
+
+func (m *promMetrics) ObserveCache(provider string, hit bool) {
+	m.mu.Lock()
+	if hit {
+		m.cacheHits[provider]++
+	} else {
+		m.cacheMisses[provider]++
+	}
+	m.mu.Unlock()
+}
+
+func (m *promMetrics) ObserveUpdate(provider string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.updateTimes[provider]
+	if h == nil {
+		h = newHistogram(updateBuckets)
+		m.updateTimes[provider] = h
+	}
+	h.observe(d.Seconds())
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	} else {
+		m.lastSuccess[provider] = time.Now()
+	}
+	m.updates[[2]string{provider, result}]++
+}
+
+// ObserveAPIKey 记录使用 API Key 的请求, name 为 Key 的名称
+func (m *promMetrics) ObserveAPIKey(name string, status int) {
+	m.mu.Lock()
+	m.apiKeys[[2]string{name, strconv.Itoa(status)}]++
+	m.mu.Unlock()
+}
+
+// handler 输出所有指标, 获取地址库信息失败时不输出地址库相关指标
+func (m *promMetrics) handler(s ip2region.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		info, _ := s.Info()
+		m.write(w, info)
+	}
+}
+
+func (m *promMetrics) write(w io.Writer, info *ip2region.Info) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header := func(name, typ, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("ip2region_lookup_duration_seconds", "histogram", "Lookup latency by provider.")
+	for _, p := range sortedKeys(m.lookups) {
+		m.lookups[p].write(w, "ip2region_lookup_duration_seconds", labels("provider", p))
+	}
+
+	header("ip2region_lookups_total", "counter", "Lookups by provider.")
+	for _, p := range sortedKeys(m.lookups) {
+		fmt.Fprintf(w, "ip2region_lookups_total{%s} %d\n", labels("provider", p), m.lookups[p].count)
+	}
+
+	header("ip2region_lookup_errors_total", "counter", "Failed lookups by provider and error type.")
+	for _, k := range sortedKeys(m.lookupErrors) {
+		fmt.Fprintf(w, "ip2region_lookup_errors_total{%s} %d\n", labels("provider", k[0], "type", k[1]), m.lookupErrors[k])
+	}
+
+	header("ip2region_xdb_io_reads_total", "counter", "File reads performed by xdb lookups.")
+	for _, p := range sortedKeys(m.ioReads) {
+		fmt.Fprintf(w, "ip2region_xdb_io_reads_total{%s} %d\n", labels("provider", p), m.ioReads[p])
+	}
+
+	header("ip2region_cache_hits_total", "counter", "Lookups served from the result cache.")
+	for _, p := range sortedKeys(m.cacheHits) {
+		fmt.Fprintf(w, "ip2region_cache_hits_total{%s} %d\n", labels("provider", p), m.cacheHits[p])
+	}
+
+	header("ip2region_cache_misses_total", "counter", "Lookups not found in the result cache.")
+	for _, p := range sortedKeys(m.cacheMisses) {
+		fmt.Fprintf(w, "ip2region_cache_misses_total{%s} %d\n", labels("provider", p), m.cacheMisses[p])
+	}
+
+	header("ip2region_cache_hit_ratio", "gauge", "Ratio of lookups served from the result cache.")
+	providers := map[string]bool{}
+	for p := range m.cacheHits {
+		providers[p] = true
+	}
+	for p := range m.cacheMisses {
+		providers[p] = true
+	}
+	for _, p := range sortedKeys(providers) {
+		hits, misses := m.cacheHits[p], m.cacheMisses[p]
+		fmt.Fprintf(w, "ip2region_cache_hit_ratio{%s} %s\n", labels("provider", p), formatFloat(float64(hits)/float64(hits+misses)))
+	}
+
+	header("ip2region_updates_total", "counter", "Database updates by provider and result.")
+	for _, k := range sortedKeys(m.updates) {
+		fmt.Fprintf(w, "ip2region_updates_total{%s} %d\n", labels("provider", k[0], "result", k[1]), m.updates[k])
+	}
+
+	header("ip2region_update_duration_seconds", "histogram", "Database update duration by provider.")
+	for _, p := range sortedKeys(m.updateTimes) {
+		m.updateTimes[p].write(w, "ip2region_update_duration_seconds", labels("provider", p))
+	}
+
+	header("ip2region_update_last_success_timestamp_seconds", "gauge", "Time of the last successful update.")
+	for _, p := range sortedKeys(m.lastSuccess) {
+		fmt.Fprintf(w, "ip2region_update_last_success_timestamp_seconds{%s} %d\n", labels("provider", p), m.lastSuccess[p].Unix())
+	}
+
+	header("ip2region_api_key_requests_total", "counter", "Requests by API key name and status code.")
+	for _, k := range sortedKeys(m.apiKeys) {
+		fmt.Fprintf(w, "ip2region_api_key_requests_total{%s} %d\n", labels("key", k[0], "code", k[1]), m.apiKeys[k])
+	}
+
+	if info == nil {
+		return
+	}
+
+	l := labels("type", info.Type)
+	header("ip2region_database_size_bytes", "gauge", "Size of the loaded database.")
+	fmt.Fprintf(w, "ip2region_database_size_bytes{%s} %d\n", l, info.Size)
+
+	header("ip2region_database_stale", "gauge", "Whether the loaded database is older than the configured max age.")
+	stale := 0
+	if info.Stale {
+		stale = 1
+	}
+	fmt.Fprintf(w, "ip2region_database_stale{%s} %d\n", l, stale)
+
+	if !info.BuildTime.IsZero() {
+		header("ip2region_database_build_timestamp_seconds", "gauge", "Build time of the loaded database.")
+		fmt.Fprintf(w, "ip2region_database_build_timestamp_seconds{%s} %d\n", l, info.BuildTime.Unix())
+
+		header("ip2region_database_age_seconds", "gauge", "Age of the loaded database.")
+		fmt.Fprintf(w, "ip2region_database_age_seconds{%s} %s\n", l, formatFloat(info.Age().Seconds()))
+	}
+}
+
+// histogram 累积直方图, 不是并发安全的, 由 promMetrics.mu 保护
+type histogram struct {
+	bounds []float64
+	counts []uint64 // 每个区间的数量, 最后一个为 +Inf
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i]++
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(w io.Writer, name, labels string) {
+	var cum uint64
+	for i, b := range h.bounds {
+		cum += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, labels, formatFloat(b), cum)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// labels 将成对的名称和值格式化为 Prometheus 标签
+func labels(kv ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(kv[i])
+		b.WriteString("=\"")
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(kv[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[K string | [2]string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+	return keys
+}
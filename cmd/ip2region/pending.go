@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cnk3x/ip2region"
+)
+
+// pendingProvider 在后台打开地址库, 使 web 服务可以先开始监听。
+// 打开完成前所有操作返回 ip2region.ErrClosed, 对应 503。
+type pendingProvider struct {
+	mu     sync.RWMutex
+	p      ip2region.Provider
+	err    error
+	closed bool
+}
+
+// openPending 在后台调用 open, 失败时调用 onError
+func openPending(open func() (ip2region.Provider, error), onError func(error)) *pendingProvider {
+	pp := &pendingProvider{}
+	go func() {
+		p, err := open()
+
+		pp.mu.Lock()
+		if err == nil && pp.closed {
+			err = ip2region.ErrClosed
+		}
+		if err != nil {
+			// 打开失败时 open 可能同时返回初始化了一半的 Provider, 不能继续使用
+			if p != nil {
+				p.Close()
+			}
+			p = nil
+		}
+		pp.p, pp.err = p, err
+		pp.mu.Unlock()
+
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}()
+	return pp
+}
+
+func (pp *pendingProvider) get() (ip2region.Provider, error) {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+
+	switch {
+	case pp.p != nil:
+		return pp.p, nil
+	case pp.err != nil:
+		return nil, fmt.Errorf("%w: %w", ip2region.ErrClosed, pp.err)
+	default:
+		return nil, fmt.Errorf("%w: 地址库正在打开", ip2region.ErrClosed)
+	}
+}
+
+func (pp *pendingProvider) Search(ctx context.Context, ip string, langs ...string) (*ip2region.Result, error) {
+	p, err := pp.get()
+	if err != nil {
+		return nil, err
+	}
+	return p.Search(ctx, ip, langs...)
+}
+
+func (pp *pendingProvider) Update(ctx context.Context) error {
+	p, err := pp.get()
+	if err != nil {
+		return err
+	}
+	return p.Update(ctx)
+}
+
+func (pp *pendingProvider) Info() (*ip2region.Info, error) {
+	p, err := pp.get()
+	if err != nil {
+		return nil, err
+	}
+	return p.Info()
+}
+
+func (pp *pendingProvider) Close() error {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+
+	pp.closed = true
+	if pp.p != nil {
+		return pp.p.Close()
+	}
+	return nil
+}
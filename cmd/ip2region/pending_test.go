@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cnk3x/ip2region"
+)
+
+// closeProvider 记录是否已关闭的 Provider
+type closeProvider struct {
+	stubProvider
+	closed bool
+}
+
+func (p *closeProvider) Close() error { p.closed = true; return nil }
+
+func TestOpenPending(t *testing.T) {
+	errOpen := errors.New("init failed")
+
+	tests := []struct {
+		name    string
+		p       *closeProvider
+		err     error
+		wantErr error
+		closed  bool
+	}{
+		{"ok", &closeProvider{}, nil, nil, false},
+		// 打开失败时返回的 Provider 不能使用, 并且需要关闭
+		{"error with provider", &closeProvider{}, errOpen, errOpen, true},
+		{"error without provider", nil, errOpen, errOpen, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failed := make(chan error, 1)
+			pp := openPending(func() (ip2region.Provider, error) {
+				if tt.p == nil {
+					return nil, tt.err
+				}
+				return tt.p, tt.err
+			}, func(err error) { failed <- err })
+
+			// 等待后台打开完成
+			if tt.err != nil {
+				<-failed
+			} else {
+				for {
+					if _, err := pp.get(); err == nil {
+						break
+					}
+				}
+			}
+
+			_, err := pp.Search(context.Background(), "1.2.3.4")
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Search() err = %v", err)
+			}
+			if tt.wantErr != nil && (!errors.Is(err, tt.wantErr) || !errors.Is(err, ip2region.ErrClosed)) {
+				t.Errorf("Search() err = %v, want %v and %v", err, tt.wantErr, ip2region.ErrClosed)
+			}
+			if tt.p != nil && tt.p.closed != tt.closed {
+				t.Errorf("closed = %v, want %v", tt.p.closed, tt.closed)
+			}
+		})
+	}
+}
@@ -13,18 +13,19 @@ func createQueryCommand() *cobra.Command {
 		Short: "查询IP地址",
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(c *cobra.Command, args []string) {
-			dbt, _ := c.Flags().GetString("type")
-			s, err := createSearcher(c.Context(), dbt)
+			s, err := createSearcher(c, nil)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
 				return
 			}
 			defer s.Close()
 
+			langs, _ := c.Flags().GetStringSlice("lang")
+
 			fmt.Fprintln(os.Stdout)
 			fmt.Fprintln(os.Stdout)
 			for _, ip := range args {
-				if r, e := s.Search(c.Context(), ip); e != nil {
+				if r, e := s.Search(c.Context(), ip, langs...); e != nil {
 					fmt.Fprintln(os.Stderr, e.Error())
 				} else {
 					fmt.Fprintln(os.Stdout, r.String())
@@ -34,6 +35,7 @@ func createQueryCommand() *cobra.Command {
 	}
 
 	c.Flags().StringP("type", "t", "mmdb", "数据库类型, xdb, mmdb")
+	c.Flags().StringSliceP("lang", "l", nil, "结果语言, 如 en, zh-CN, 可指定多个, 按顺序优先")
 	return c
 }
 
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func bindRateLimitFlags(c *cobra.Command) {
+	c.Flags().Float64("rate-limit", 0, "每个客户端每秒允许的请求数, 批量查询按IP数量计算, 0 表示不限流")
+	c.Flags().Int("rate-burst", 0, "每个客户端允许的突发请求数, 默认为 rate-limit 的 2 倍, 至少为 1")
+	c.Flags().StringSlice("rate-exempt", nil, "不限流的客户端地址或网段")
+}
+
+// newRateLimiter 按命令行参数创建限流器, 未启用时返回 nil, perKey 表示 API Key 有单独的限流设置
+func newRateLimiter(c *cobra.Command, perKey bool) (l *rateLimiter, err error) {
+	rate, _ := c.Flags().GetFloat64("rate-limit")
+	burst, _ := c.Flags().GetInt("rate-burst")
+	exempt, _ := c.Flags().GetStringSlice("rate-exempt")
+
+	if rate < 0 || burst < 0 {
+		return nil, errors.New("--rate-limit 和 --rate-burst 不能小于 0")
+	}
+
+	if rate == 0 && !perKey {
+		return nil, nil
+	}
+
+	if burst == 0 {
+		burst = max(int(math.Ceil(rate*2)), 1)
+	}
+
+	l = &rateLimiter{rate: rate, burst: burst, buckets: map[string]*bucket{}}
+	if l.exempt, err = parsePrefixes(exempt); err != nil {
+		return nil, fmt.Errorf("无效的 --rate-exempt: %w", err)
+	}
+	return
+}
+
+// rateLimiter 令牌桶限流, 每个客户端一个桶, 以 rate 的速度补充令牌, 最多 burst 个,
+// 使用 API Key 的请求按 Key 限流, Key 设置了 rate 时使用 Key 的设置
+type rateLimiter struct {
+	rate   float64
+	burst  int
+	exempt []netip.Prefix
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// take 从 key 的桶中取 cost 个令牌, 返回剩余令牌数, 令牌不足时返回 ok=false 和需要等待的时间。
+// cost 超过 burst 时桶满即可通过, 令牌数变为负数, 之后的请求需要等待补足
+func (l *rateLimiter) take(key string, rate float64, burst int, cost float64, now time.Time) (ok bool, remaining int, wait time.Duration, reset time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b := l.buckets[key]
+	if b == nil || b.rate != rate || b.burst != burst {
+		// 新的桶, 或 Key 的限流设置已被修改
+		b = &bucket{rate: rate, burst: burst, tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if need := math.Min(cost, float64(burst)); b.tokens >= need {
+		b.tokens -= cost
+		ok = true
+	} else {
+		wait = refillTime(need-b.tokens, rate)
+	}
+
+	remaining = max(int(b.tokens), 0)
+	reset = refillTime(float64(burst)-b.tokens, rate)
+	return
+}
+
+// refillTime 以 rate 的速度补充 n 个令牌需要的时间
+func refillTime(n, rate float64) time.Duration {
+	return time.Duration(n / rate * float64(time.Second))
+}
+
+// sweep 每分钟清理一次已经补满的桶, 它们与新建的桶没有区别
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refillTime(float64(b.burst), b.rate) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// limit 返回请求的限流键和设置, 使用 API Key 时按 Key 限流, 否则按客户端地址 ip 限流,
+// 不需要限流(豁免地址或速率为 0)时返回 false
+func (l *rateLimiter) limit(k *apiKey, ip string) (key string, rate float64, burst int, limited bool) {
+	if k != nil {
+		if k.Rate > 0 {
+			burst = k.Burst
+			if burst <= 0 {
+				burst = max(int(math.Ceil(k.Rate*2)), 1)
+			}
+			return "apikey:" + k.Name, k.Rate, burst, true
+		}
+		return "apikey:" + k.Name, l.rate, l.burst, l.rate > 0
+	}
+
+	if l.rate <= 0 {
+		return
+	}
+
+	if addr := parseHostAddr(ip); addr.IsValid() {
+		if containsAddr(l.exempt, addr) {
+			return
+		}
+		ip = addr.String()
+	}
+	return "ip:" + ip, l.rate, l.burst, true
+}
+
+func (l *rateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		k := apiKeyFrom(r.Context())
+		if !l.allow(w, r, k) {
+			return
+		}
+
+		key, rate, burst, limited := l.limit(k, clientIP(r))
+		if !limited {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 批量查询在读取请求后按IP数量补扣令牌
+		charge := func(n int) (bool, time.Duration) {
+			ok, _, wait, _ := l.take(key, rate, burst, float64(n), time.Now())
+			return ok, wait
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rateChargeCtxKey{}, charge)))
+	})
+}
+
+// allow 从请求的限流桶中取一个令牌并设置 RateLimit-* 响应头, 令牌不足时返回 429 和 false
+func (l *rateLimiter) allow(w http.ResponseWriter, r *http.Request, k *apiKey) bool {
+	key, rate, burst, limited := l.limit(k, clientIP(r))
+	if !limited {
+		return true
+	}
+
+	ok, remaining, wait, reset := l.take(key, rate, burst, 1, time.Now())
+
+	h := w.Header()
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", burst, ceilSeconds(refillTime(float64(burst), rate))))
+	h.Set("RateLimit-Limit", strconv.Itoa(burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+
+	if !ok {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+		webErr(w, r, errTooManyRequests, http.StatusTooManyRequests)
+	}
+	return ok
+}
+
+var errTooManyRequests = errors.New("请求过于频繁")
+
+type rateChargeCtxKey struct{}
+
+// chargeRate 从请求的限流桶中再取 n 个令牌, 请求未被限流时总是成功, 令牌不足时返回需要等待的时间
+func chargeRate(ctx context.Context, n int) (ok bool, wait time.Duration) {
+	charge, _ := ctx.Value(rateChargeCtxKey{}).(func(int) (bool, time.Duration))
+	if charge == nil || n <= 0 {
+		return true, 0
+	}
+	return charge(n)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	type step struct {
+		after     time.Duration // 距上一步的时间
+		cost      float64
+		ok        bool
+		remaining int
+		wait      time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		steps []step
+	}{
+		{"burst", 1, 3, []step{
+			{0, 1, true, 2, 0},
+			{0, 1, true, 1, 0},
+			{0, 1, true, 0, 0},
+			{0, 1, false, 0, time.Second},
+			{500 * time.Millisecond, 1, false, 0, 500 * time.Millisecond},
+			{500 * time.Millisecond, 1, true, 0, 0},
+		}},
+		{"refill capped at burst", 10, 2, []step{
+			{0, 2, true, 0, 0},
+			{time.Hour, 1, true, 1, 0},
+			{0, 1, true, 0, 0},
+			{0, 1, false, 0, 100 * time.Millisecond},
+		}},
+		{"weighted", 10, 20, []step{
+			{0, 15, true, 5, 0},
+			{0, 10, false, 5, 500 * time.Millisecond},
+			{500 * time.Millisecond, 10, true, 0, 0},
+		}},
+		// cost 超过 burst 时桶满即可通过, 之后需要等待补足欠下的令牌
+		{"cost over burst", 10, 20, []step{
+			{0, 100, true, 0, 0},
+			{0, 1, false, 0, 8100 * time.Millisecond},
+			{8 * time.Second, 1, false, 0, 100 * time.Millisecond},
+			{100 * time.Millisecond, 1, true, 0, 0},
+		}},
+		{"cost over burst waits for full bucket", 10, 20, []step{
+			{0, 5, true, 15, 0},
+			{0, 100, false, 15, 500 * time.Millisecond},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &rateLimiter{buckets: map[string]*bucket{}}
+			now := time.Unix(1700000000, 0)
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				ok, remaining, wait, _ := l.take("k", tt.rate, tt.burst, s.cost, now)
+				if ok != s.ok || remaining != s.remaining || wait != s.wait {
+					t.Errorf("step %d: take() = %v, %d, %v, want %v, %d, %v", i, ok, remaining, wait, s.ok, s.remaining, s.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterTakeSettingsChanged(t *testing.T) {
+	l := &rateLimiter{buckets: map[string]*bucket{}}
+	now := time.Unix(1700000000, 0)
+
+	if ok, _, _, _ := l.take("k", 1, 1, 1, now); !ok {
+		t.Fatal("first take failed")
+	}
+	if ok, _, _, _ := l.take("k", 1, 1, 1, now); ok {
+		t.Fatal("take from empty bucket succeeded")
+	}
+	// API Key 的限流设置被修改后使用新的桶
+	if ok, remaining, _, _ := l.take("k", 1, 5, 1, now); !ok || remaining != 4 {
+		t.Errorf("take() after settings changed = %v, %d, want true, 4", ok, remaining)
+	}
+}
+
+func TestRateLimiterLimit(t *testing.T) {
+	l := &rateLimiter{rate: 2, burst: 4, exempt: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+
+	tests := []struct {
+		name    string
+		k       *apiKey
+		ip      string
+		key     string
+		rate    float64
+		burst   int
+		limited bool
+	}{
+		{"ip", nil, "1.2.3.4", "ip:1.2.3.4", 2, 4, true},
+		{"ipv4 mapped", nil, "::ffff:1.2.3.4", "ip:1.2.3.4", 2, 4, true},
+		{"exempt", nil, "10.1.2.3", "", 0, 0, false},
+		{"exempt mapped", nil, "::ffff:10.1.2.3", "", 0, 0, false},
+		{"api key default", &apiKey{Name: "a"}, "10.1.2.3", "apikey:a", 2, 4, true},
+		{"api key rate", &apiKey{Name: "b", Rate: 5}, "1.2.3.4", "apikey:b", 5, 10, true},
+		{"api key rate and burst", &apiKey{Name: "c", Rate: 0.5, Burst: 3}, "1.2.3.4", "apikey:c", 0.5, 3, true},
+		{"api key small rate", &apiKey{Name: "d", Rate: 0.1}, "1.2.3.4", "apikey:d", 0.1, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, rate, burst, limited := l.limit(tt.k, tt.ip)
+			if key != tt.key || rate != tt.rate || burst != tt.burst || limited != tt.limited {
+				t.Errorf("limit() = %q, %v, %d, %v, want %q, %v, %d, %v", key, rate, burst, limited, tt.key, tt.rate, tt.burst, tt.limited)
+			}
+		})
+	}
+}
+
+func TestRateLimiterHandler(t *testing.T) {
+	l := &rateLimiter{rate: 1, burst: 10, buckets: map[string]*bucket{}}
+
+	var charged []bool
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, _ := chargeRate(r.Context(), 5)
+		charged = append(charged, ok)
+	}))
+
+	serve := func(path, remoteAddr string, header http.Header) int {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = remoteAddr
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// 每次请求 1 个令牌加上补扣的 5 个
+	for i, want := range []bool{true, false} {
+		if code := serve("/v1/lookup/1.1.1.1", "1.2.3.4:1000", nil); code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i, code)
+		}
+		if charged[i] != want {
+			t.Errorf("request %d: charge = %v, want %v", i, charged[i], want)
+		}
+	}
+
+	// 剩余 3 个令牌, 改变请求头不会换到新的桶
+	for range 3 {
+		serve("/v1/lookup/1.1.1.1", "1.2.3.4:1000", http.Header{"X-Api-Key": {"spoofed"}})
+	}
+	if code := serve("/v1/lookup/1.1.1.1", "1.2.3.4:1001", http.Header{"X-Api-Key": {"spoofed-again"}}); code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", code)
+	}
+
+	if code := serve("/healthz", "1.2.3.4:1000", nil); code != http.StatusOK {
+		t.Errorf("/healthz status = %d, want 200", code)
+	}
+	if code := serve("/v1/lookup/1.1.1.1", "5.6.7.8:1000", nil); code != http.StatusOK {
+		t.Errorf("other client status = %d, want 200", code)
+	}
+}
+
+func TestChargeRateWithoutLimiter(t *testing.T) {
+	if ok, wait := chargeRate(context.Background(), 1000); !ok || wait != 0 {
+		t.Errorf("chargeRate() = %v, %v, want true, 0", ok, wait)
+	}
+}
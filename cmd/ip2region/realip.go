@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// realIP 从受信任代理转发的请求头中获取客户端地址, 替代 middleware.RealIP。
+// 只有直连地址属于受信任代理时才读取请求头, 依次使用:
+// CF-Connecting-IP(启用 cloudflare 时), Forwarded(RFC 7239), X-Forwarded-For。
+// 后两者从右向左查找, 跳过受信任代理, 第一个不受信任的地址即为客户端地址。
+type realIP struct {
+	trusted    []netip.Prefix
+	cloudflare bool
+}
+
+func newRealIP(proxies []string, cloudflare bool) (ri *realIP, err error) {
+	ri = &realIP{cloudflare: cloudflare}
+	if ri.trusted, err = parsePrefixes(proxies); err != nil {
+		return nil, fmt.Errorf("无效的受信任代理: %w", err)
+	}
+	return
+}
+
+// parsePrefixes 解析网段列表, 单个地址视为只包含该地址的网段
+func parsePrefixes(list []string) (prefixes []netip.Prefix, err error) {
+	for _, s := range list {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+
+		var p netip.Prefix
+		if strings.Contains(s, "/") {
+			if p, err = netip.ParsePrefix(s); err != nil {
+				return nil, err
+			}
+		} else {
+			addr, e := netip.ParseAddr(s)
+			if e != nil {
+				return nil, e
+			}
+			p = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return
+}
+
+// containsAddr 地址是否属于任一网段
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ri *realIP) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := ri.resolve(r); ip.IsValid() {
+			r.RemoteAddr = ip.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (ri *realIP) isTrusted(addr netip.Addr) bool {
+	return containsAddr(ri.trusted, addr)
+}
+
+// resolve 返回客户端地址, 直连地址无法解析时返回无效地址
+func (ri *realIP) resolve(r *http.Request) netip.Addr {
+	peer := parseHostAddr(r.RemoteAddr)
+	if !peer.IsValid() || len(ri.trusted) == 0 || !ri.isTrusted(peer) {
+		return peer
+	}
+
+	if ri.cloudflare {
+		if ip := parseHostAddr(r.Header.Get("CF-Connecting-IP")); ip.IsValid() {
+			return ip
+		}
+	}
+
+	hops := forwardedFor(r.Header.Values("Forwarded"))
+	if hops == nil {
+		for _, v := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(v, ",")...)
+		}
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHostAddr(hops[i])
+		if !ip.IsValid() {
+			// unknown 或混淆的标识, 无法继续向左追溯
+			break
+		}
+		client = ip
+		if !ri.isTrusted(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFor 返回 Forwarded 头中所有 for 参数的值, 按出现顺序
+func forwardedFor(values []string) (hops []string) {
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				if k, v, ok := strings.Cut(strings.TrimSpace(pair), "="); ok && strings.EqualFold(strings.TrimSpace(k), "for") {
+					hops = append(hops, strings.Trim(strings.TrimSpace(v), `"`))
+				}
+			}
+		}
+	}
+	return
+}
+
+// parseHostAddr 解析可能带端口的地址, 如 192.0.2.1, 192.0.2.1:80, [2001:db8::1]:80, 2001:db8::1
+func parseHostAddr(s string) netip.Addr {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().WithZone("")
+	}
+
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	} else {
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	}
+
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().WithZone("")
+	}
+	return netip.Addr{}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIPResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    []string
+		cloudflare bool
+		remote     string
+		headers    http.Header
+		want       string
+	}{
+		{"no trusted proxies", nil, false, "192.0.2.1:1234",
+			http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "192.0.2.1"},
+		{"untrusted peer ignores headers", []string{"10.0.0.0/8"}, false, "192.0.2.1:1234",
+			http.Header{"X-Forwarded-For": {"198.51.100.1"}, "Forwarded": {"for=198.51.100.2"}}, "192.0.2.1"},
+		{"trusted peer without headers", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			nil, "10.0.0.1"},
+		{"xff single hop", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+		{"xff skips trusted hops", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"198.51.100.1, 10.0.0.3, 10.0.0.2"}}, "198.51.100.1"},
+		{"xff spoofed leftmost", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1, 10.0.0.2"}}, "198.51.100.1"},
+		{"xff multiple headers", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1, 198.51.100.1", "10.0.0.2"}}, "198.51.100.1"},
+		{"xff all trusted", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"xff invalid hop stops", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"1.1.1.1, unknown, 10.0.0.2"}}, "10.0.0.2"},
+		{"xff ipv6 with port", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"[2001:db8::1]:4711"}}, "2001:db8::1"},
+		{"xff ipv4-mapped", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"::ffff:198.51.100.1"}}, "198.51.100.1"},
+		{"forwarded", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"Forwarded": {"for=198.51.100.1;proto=https;by=10.0.0.1"}}, "198.51.100.1"},
+		{"forwarded quoted ipv6 with port", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"Forwarded": {`for="[2001:db8:cafe::17]:4711"`}}, "2001:db8:cafe::17"},
+		{"forwarded skips trusted hops", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"Forwarded": {"for=1.1.1.1, For=198.51.100.1", `for="10.0.0.2:80"`}}, "198.51.100.1"},
+		{"forwarded obfuscated stops", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"Forwarded": {"for=198.51.100.1, for=_hidden, for=10.0.0.2"}}, "10.0.0.2"},
+		{"forwarded preferred over xff", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"Forwarded": {"for=198.51.100.1"}, "X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.1"},
+		{"trusted single address", []string{"10.0.0.1"}, false, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"198.51.100.1, 10.0.0.2"}}, "10.0.0.2"},
+		{"trusted ipv6 peer", []string{"fd00::/8"}, false, "[fd00::1]:1234",
+			http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+		{"cloudflare", []string{"10.0.0.0/8"}, true, "10.0.0.1:1234",
+			http.Header{"Cf-Connecting-Ip": {"198.51.100.1"}, "X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.1"},
+		{"cloudflare missing header", []string{"10.0.0.0/8"}, true, "10.0.0.1:1234",
+			http.Header{"X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.2"},
+		{"cloudflare disabled", []string{"10.0.0.0/8"}, false, "10.0.0.1:1234",
+			http.Header{"Cf-Connecting-Ip": {"198.51.100.1"}, "X-Forwarded-For": {"198.51.100.2"}}, "198.51.100.2"},
+		{"cloudflare untrusted peer", []string{"10.0.0.0/8"}, true, "192.0.2.1:1234",
+			http.Header{"Cf-Connecting-Ip": {"198.51.100.1"}}, "192.0.2.1"},
+		{"invalid remote addr", []string{"10.0.0.0/8"}, false, "pipe",
+			http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "invalid IP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ri, err := newRealIP(tt.trusted, tt.cloudflare)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			for k, v := range tt.headers {
+				r.Header[k] = v
+			}
+
+			if got := ri.resolve(r).String(); got != tt.want {
+				t.Errorf("resolve() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	got, err := parsePrefixes([]string{" 10.1.2.3/8 ", "", "192.0.2.1", "::ffff:192.0.2.2", "2001:db8::1/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+		netip.MustParsePrefix("192.0.2.2/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("parsePrefixes() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parsePrefixes()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	for _, s := range []string{"10.0.0.0/33", "bad", "10.0.0.1/"} {
+		if _, err := parsePrefixes([]string{s}); err == nil {
+			t.Errorf("parsePrefixes(%q) should fail", s)
+		}
+	}
+}
+
+func TestParseHostAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"192.0.2.1", "192.0.2.1"},
+		{" 192.0.2.1:80 ", "192.0.2.1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"[2001:db8::1]:80", "2001:db8::1"},
+		{"fe80::1%eth0", "fe80::1"},
+		{"::ffff:192.0.2.1", "192.0.2.1"},
+		{"unknown", "invalid IP"},
+		{"_hidden", "invalid IP"},
+		{"", "invalid IP"},
+	}
+
+	for _, tt := range tests {
+		if got := parseHostAddr(tt.in).String(); got != tt.want {
+			t.Errorf("parseHostAddr(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cnk3x/ip2region/pkg/fileio"
+	"github.com/spf13/cobra"
+)
+
+func createRollbackCommand() *cobra.Command {
+	c := &cobra.Command{
+		Use:   "rollback",
+		Short: "回滚数据库到上一个版本",
+		Args:  cobra.NoArgs,
+		Run: func(c *cobra.Command, args []string) {
+			if list, _ := c.Flags().GetBool("list"); list {
+				versions, err := fileio.ListVersions(dataFile(c))
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					return
+				}
+
+				for _, v := range versions {
+					mark := " "
+					if v.Active {
+						mark = "*"
+					}
+					fmt.Fprintf(os.Stdout, "%s %s %8s %s\n", mark, v.Name, fileio.HumanBytes(v.Size), v.ModTime.Format("2006-01-02 15:04:05"))
+				}
+				return
+			}
+
+			s, err := createSearcher(c, nil)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				return
+			}
+			defer s.Close()
+
+			r, ok := s.(interface {
+				Rollback(ctx context.Context) error
+			})
+			if !ok {
+				fmt.Fprintln(os.Stderr, "数据库不支持回滚")
+				return
+			}
+
+			if err = r.Rollback(c.Context()); err != nil {
+				if errors.Is(err, fileio.ErrNoVersion) {
+					fmt.Fprintln(os.Stderr, "没有可回滚的版本, 更新时需要设置 --keep-versions 保留版本")
+					return
+				}
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+			}
+		},
+	}
+
+	c.Flags().StringP("type", "t", "mmdb", "数据库类型, xdb, mmdb")
+	c.Flags().Bool("list", false, "列出所有版本")
+	return c
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newServer 按命令行参数创建 http.Server
+func newServer(c *cobra.Command, handler http.Handler) *http.Server {
+	listen, _ := c.Flags().GetString("listen")
+	readTimeout, _ := c.Flags().GetDuration("read-timeout")
+	readHeaderTimeout, _ := c.Flags().GetDuration("read-header-timeout")
+	writeTimeout, _ := c.Flags().GetDuration("write-timeout")
+	idleTimeout, _ := c.Flags().GetDuration("idle-timeout")
+
+	return &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+func bindServerFlags(c *cobra.Command) {
+	c.Flags().Duration("read-timeout", 30*time.Second, "读取整个请求的超时时间, 0 表示不限制")
+	c.Flags().Duration("read-header-timeout", 10*time.Second, "读取请求头的超时时间, 0 表示使用 read-timeout")
+	c.Flags().Duration("write-timeout", 60*time.Second, "写响应的超时时间, 流式批量查询每写出一批结果顺延一次, 0 表示不限制")
+	c.Flags().Duration("idle-timeout", 120*time.Second, "keep-alive 连接的空闲超时时间, 0 表示使用 read-timeout")
+	c.Flags().Duration("shutdown-timeout", 15*time.Second, "收到退出信号后等待处理中的请求完成的最长时间")
+}
+
+// serve 开始监听并提供服务, ctx 结束后停止接受新连接, 最多等待 timeout 让处理中的请求完成。
+// 监听失败或 ctx 因错误结束(context.Cause 不是 context.Canceled)时返回错误。
+func serve(ctx context.Context, srv *http.Server, timeout time.Duration) (err error) {
+	var ln net.Listener
+	if ln, err = net.Listen("tcp", srv.Addr); err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", srv.Addr, err)
+	}
+
+	errc := make(chan error, 1)
+	if srv.TLSConfig != nil {
+		// 证书由 TLSConfig.GetCertificate 提供, ServeTLS 会自动启用 HTTP/2
+		slog.Info("listen", "addr", ln.Addr().String(), "tls", true)
+		go func() { errc <- srv.ServeTLS(ln, "", "") }()
+	} else {
+		slog.Info("listen", "addr", ln.Addr().String())
+		go func() { errc <- srv.Serve(ln) }()
+	}
+
+	select {
+	case err = <-errc:
+		return fmt.Errorf("web服务异常退出: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("正在关闭web服务", "timeout", timeout.String())
+	sctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err = srv.Shutdown(sctx); err != nil {
+		srv.Close()
+		return fmt.Errorf("等待请求完成超时, 已强制关闭: %w", err)
+	}
+	slog.Info("web服务已关闭")
+
+	if cause := context.Cause(ctx); !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return nil
+}
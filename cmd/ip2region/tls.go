@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cnk3x/ip2region/pkg/fileio"
+	"github.com/spf13/cobra"
+)
+
+// 轮询证书文件的间隔
+const certWatchInterval = 10 * time.Second
+
+func bindTLSFlags(c *cobra.Command) {
+	c.Flags().String("tls-cert", "", "TLS 证书文件(PEM), 与 --tls-key 同时设置时启用 HTTPS 和 HTTP/2, 文件更新后自动重新加载")
+	c.Flags().String("tls-key", "", "TLS 私钥文件(PEM)")
+	c.Flags().String("tls-client-ca", "", "校验客户端证书的 CA 文件(PEM), 设置后启用双向认证")
+	c.Flags().String("tls-client-auth", "require", "客户端证书校验方式, require: 必须提供证书, optional: 提供时校验")
+}
+
+// newTLSConfig 按命令行参数创建 TLS 配置, 未设置证书时返回 nil
+func newTLSConfig(ctx context.Context, c *cobra.Command) (cfg *tls.Config, err error) {
+	certFile, _ := c.Flags().GetString("tls-cert")
+	keyFile, _ := c.Flags().GetString("tls-key")
+	clientCA, _ := c.Flags().GetString("tls-client-ca")
+	clientAuth, _ := c.Flags().GetString("tls-client-auth")
+
+	if certFile == "" && keyFile == "" {
+		if clientCA != "" {
+			return nil, errors.New("--tls-client-ca 需要同时设置 --tls-cert 和 --tls-key")
+		}
+		return nil, nil
+	}
+
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("--tls-cert 和 --tls-key 必须同时设置")
+	}
+
+	var cr *certReloader
+	if cr, err = newCertReloader(ctx, certFile, keyFile); err != nil {
+		return
+	}
+
+	cfg = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+
+	if clientCA != "" {
+		var pem []byte
+		if pem, err = os.ReadFile(clientCA); err != nil {
+			return nil, err
+		}
+
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("客户端 CA 文件中没有有效的证书: %s", clientCA)
+		}
+
+		switch clientAuth {
+		case "require":
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("无效的 --tls-client-auth: %s", clientAuth)
+		}
+	}
+	return
+}
+
+// certReloader 证书或私钥文件更新后自动重新加载, 加载失败时继续使用旧证书
+type certReloader struct {
+	certFile, keyFile string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func newCertReloader(ctx context.Context, certFile, keyFile string) (r *certReloader, err error) {
+	r = &certReloader{certFile: certFile, keyFile: keyFile}
+
+	var cert tls.Certificate
+	if cert, err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return nil, fmt.Errorf("加载证书失败: %w", err)
+	}
+	r.cert = &cert
+
+	fileio.Watch(ctx, certFile, certWatchInterval, r.reload)
+	fileio.Watch(ctx, keyFile, certWatchInterval, r.reload)
+	return
+}
+
+func (r *certReloader) reload() {
+	// 证书和私钥可能先后更新, 不匹配时等待另一个文件更新后再次加载
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		slog.Warn("证书重新加载失败，继续使用旧证书", "cert", r.certFile, "err", err)
+		return
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	slog.Info("证书已重新加载", "cert", r.certFile)
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
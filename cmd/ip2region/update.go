@@ -12,8 +12,7 @@ func createUpdateCommand() *cobra.Command {
 		Use:   "update",
 		Short: "更新数据库",
 		Run: func(c *cobra.Command, args []string) {
-			dbt, _ := c.Flags().GetString("type")
-			s, err := createSearcher(c.Context(), dbt)
+			s, err := createSearcher(c, nil)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "%v\n", err)
 				return
@@ -0,0 +1,193 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+//go:embed openapi.json
+var openapiDoc []byte
+
+// 响应格式
+const (
+	formatJSON = "json"
+	formatText = "text"
+	formatXML  = "xml"
+	formatCSV  = "csv"
+)
+
+var formatTypes = map[string]string{
+	formatJSON: "application/json",
+	formatText: "text/plain",
+	formatXML:  "application/xml",
+	formatCSV:  "text/csv",
+}
+
+// v1Place 地区, 字段含义见 openapi.json 中的 Place
+type v1Place struct {
+	Name      string `json:"name,omitempty" xml:"name,omitempty"`
+	Code      string `json:"code,omitempty" xml:"code,omitempty"`
+	GeoNameID uint   `json:"geoname_id,omitempty" xml:"geoname_id,omitempty"`
+}
+
+// v1Lookup /v1 接口的查询结果, 字段含义见 openapi.json 中的 Lookup, 修改时需保持兼容
+type v1Lookup struct {
+	XMLName     xml.Name `json:"-" xml:"lookup"`
+	IP          string   `json:"ip" xml:"ip"`
+	Kind        string   `json:"kind" xml:"kind"`
+	Reserved    bool     `json:"reserved" xml:"reserved"`
+	Continent   *v1Place `json:"continent,omitempty" xml:"continent,omitempty"`
+	Country     *v1Place `json:"country,omitempty" xml:"country,omitempty"`
+	Subdivision *v1Place `json:"subdivision,omitempty" xml:"subdivision,omitempty"`
+	City        *v1Place `json:"city,omitempty" xml:"city,omitempty"`
+	ISP         string   `json:"isp,omitempty" xml:"isp,omitempty"`
+
+	text string
+}
+
+// v1Error /v1 接口的错误信息
+type v1Error struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Status  int      `json:"status" xml:"status"`
+	Error   string   `json:"error" xml:"message"`
+}
+
+var v1CSVHeader = []string{"ip", "kind", "reserved", "continent", "continent_code", "country", "country_code", "subdivision", "subdivision_code", "city", "isp"}
+
+func newV1Lookup(r *ip2region.Result) *v1Lookup {
+	place := func(n ip2region.Name) *v1Place {
+		if n == (ip2region.Name{}) {
+			return nil
+		}
+		return &v1Place{Name: n.Name, Code: n.Code, GeoNameID: n.ID}
+	}
+
+	kind := r.Kind
+	if kind == "" {
+		kind = ip2region.KindPublic
+	}
+
+	return &v1Lookup{
+		IP:          r.IP,
+		Kind:        string(kind),
+		Reserved:    r.Reserved,
+		Continent:   place(r.Continent),
+		Country:     place(r.Country),
+		Subdivision: place(r.Subdivision),
+		City:        place(r.City),
+		ISP:         r.ISP,
+		text:        r.IP + ": " + r.InfoText(),
+	}
+}
+
+func (l *v1Lookup) csvRecord() []string {
+	place := func(p *v1Place) (name, code string) {
+		if p != nil {
+			name, code = p.Name, p.Code
+		}
+		return
+	}
+
+	continent, continentCode := place(l.Continent)
+	country, countryCode := place(l.Country)
+	subdivision, subdivisionCode := place(l.Subdivision)
+	city, _ := place(l.City)
+	return []string{l.IP, l.Kind, strconv.FormatBool(l.Reserved), continent, continentCode, country, countryCode, subdivision, subdivisionCode, city, l.ISP}
+}
+
+// registerV1 注册 /v1 接口
+func registerV1(mux *chi.Mux, s ip2region.Provider, dbt string) {
+	lookup := func(w http.ResponseWriter, r *http.Request, ip string) {
+		result, err := s.Search(r.Context(), ip, negotiateLangs(w, r, dbt)...)
+		if err != nil {
+			v1Err(w, r, err, errStatus(err))
+			return
+		}
+		v1Respond(w, r, newV1Lookup(result), http.StatusOK)
+	}
+
+	mux.HandleFunc("GET /v1/lookup/{ip}", func(w http.ResponseWriter, r *http.Request) {
+		lookup(w, r, chi.URLParam(r, "ip"))
+	})
+
+	mux.HandleFunc("GET /v1/self", func(w http.ResponseWriter, r *http.Request) {
+		lookup(w, r, clientIP(r))
+	})
+
+	mux.HandleFunc("GET /v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(openapiDoc)
+	})
+}
+
+func v1Err(w http.ResponseWriter, r *http.Request, err error, status int) {
+	e := &v1Error{Status: status, Error: err.Error()}
+	if requestFormat(r) == formatCSV {
+		// csv 没有错误的表示方式, 错误信息以纯文本返回
+		render.Status(r, status)
+		render.PlainText(w, r, e.Error)
+		return
+	}
+	v1Respond(w, r, e, status)
+}
+
+func v1Respond(w http.ResponseWriter, r *http.Request, data any, status int) {
+	w.Header().Add("Vary", "Accept")
+	render.Status(r, status)
+
+	switch requestFormat(r) {
+	case formatText:
+		switch v := data.(type) {
+		case *v1Lookup:
+			render.PlainText(w, r, v.text)
+		case *v1Error:
+			render.PlainText(w, r, v.Error)
+		}
+	case formatXML:
+		render.XML(w, r, data)
+	case formatCSV:
+		if l, ok := data.(*v1Lookup); ok {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.WriteHeader(status)
+			cw := csv.NewWriter(w)
+			cw.Write(v1CSVHeader)
+			cw.Write(l.csvRecord())
+			cw.Flush()
+			return
+		}
+		render.JSON(w, r, data)
+	default:
+		render.JSON(w, r, data)
+	}
+}
+
+// requestFormat 响应格式, ?format= 优先于 Accept 头, 都未指定时返回空字符串
+func requestFormat(r *http.Request) string {
+	if f := strings.ToLower(r.URL.Query().Get("format")); f != "" {
+		if _, ok := formatTypes[f]; ok {
+			return f
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mt = strings.ToLower(strings.TrimSpace(mt))
+
+		q := qValue(params)
+		for f, t := range formatTypes {
+			if (mt == t || f == formatXML && mt == "text/xml") && q > bestQ {
+				best, bestQ = f, q
+			}
+		}
+	}
+	return best
+}
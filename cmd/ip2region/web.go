@@ -1,11 +1,17 @@
 package main
 
 import (
-	"log/slog"
+	"context"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
+	"github.com/cnk3x/ip2region"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -18,62 +24,235 @@ func createWebCommand() *cobra.Command {
 		Use:   "web",
 		Short: "启动web服务",
 		Args:  cobra.NoArgs,
-		Run: func(c *cobra.Command, args []string) {
-			dbt, _ := c.Flags().GetString("type")
-			s, err := createSearcher(c.Context(), dbt)
+		// 参数错误以外的错误不需要打印用法
+		SilenceUsage: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			proxies, _ := c.Flags().GetStringSlice("trusted-proxies")
+			cloudflare, _ := c.Flags().GetBool("cloudflare")
+			ri, err := newRealIP(proxies, cloudflare)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(c.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			ctx, cancel := context.WithCancelCause(ctx)
+			defer cancel(nil)
+
+			tlsConfig, err := newTLSConfig(ctx, c)
+			if err != nil {
+				return err
+			}
+
+			metrics := newPromMetrics()
+			keys, err := newAPIKeys(ctx, c, metrics)
 			if err != nil {
-				slog.Error("创建搜索器失败", "type", dbt, "err", err)
-				return
+				return err
 			}
+
+			// API Key 可以单独设置限流, 启用 API Key 时总是创建限流器
+			limiter, err := newRateLimiter(c, keys != nil)
+			if err != nil {
+				return err
+			}
+			if keys != nil {
+				keys.limiter = limiter
+			}
+
+			dbt, _ := c.Flags().GetString("type")
+			// 先开始监听, 地址库在后台打开(可能需要下载), 打开完成前 /readyz 返回 503
+			s := openPending(func() (ip2region.Provider, error) {
+				return createSearcher(c, metrics)
+			}, func(err error) {
+				cancel(fmt.Errorf("创建搜索器失败(%s): %w", dbt, err))
+			})
 			defer s.Close()
 
 			mux := chi.NewMux()
-			mux.Use(middleware.Recoverer, middleware.Logger, cors.AllowAll().Handler, middleware.RealIP)
+			mux.Use(middleware.Recoverer, middleware.Logger, cors.AllowAll().Handler, ri.Handler)
+			if keys != nil {
+				mux.Use(keys.Handler)
+			}
+			if limiter != nil {
+				mux.Use(limiter.Handler)
+			}
+			if keys != nil {
+				mux.Use(keys.QuotaHandler)
+			}
 
 			mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
 				ip := r.FormValue("ip")
 				if ip == "" {
-					if ip, _, _ = net.SplitHostPort(r.RemoteAddr); ip == "" {
-						ip = r.RemoteAddr
-					}
+					ip = clientIP(r)
 				}
 
-				result, err := s.Search(r.Context(), ip)
+				// xml 和 csv 与 /v1 的格式相同
+				f := requestFormat(r)
+				v1Format := f == formatXML || f == formatCSV
+
+				result, err := s.Search(r.Context(), ip, negotiateLangs(w, r, dbt)...)
 				if err != nil {
-					webErr(w, r, err, 500)
+					if v1Format {
+						v1Err(w, r, err, errStatus(err))
+					} else {
+						webErr(w, r, err, errStatus(err))
+					}
 					return
 				}
 				result.IP = ip
+				if v1Format {
+					v1Respond(w, r, newV1Lookup(result), http.StatusOK)
+					return
+				}
 				webRespond(w, r, result, result.String(), 200)
 			})
 
-			mux.HandleFunc("/update", func(w http.ResponseWriter, r *http.Request) {
-				if err = s.Update(c.Context()); err != nil {
-					webErr(w, r, err, 500)
-				} else {
-					webRespond(w, r, render.M{"msg": "OK"}, "OK", 200)
+			registerV1(mux, s, dbt)
+			mux.HandleFunc("GET /metrics", metrics.handler(s))
+
+			batchMax, _ := c.Flags().GetInt("batch-max")
+			writeTimeout, _ := c.Flags().GetDuration("write-timeout")
+			mux.HandleFunc("POST /batch", batchHandler(s, dbt, batchMax, writeTimeout))
+
+			mux.HandleFunc("GET /info", func(w http.ResponseWriter, r *http.Request) {
+				info, err := s.Info()
+				if err != nil {
+					webErr(w, r, err, errStatus(err))
+					return
 				}
+				webRespond(w, r, info, info.String(), 200)
+			})
+
+			mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+				render.JSON(w, r, render.M{"status": "ok"})
 			})
 
-			listen, _ := c.Flags().GetString("listen")
-			slog.Info("listen", "addr", listen)
-			http.ListenAndServe(listen, mux)
+			readyz := func(w http.ResponseWriter, r *http.Request) {
+				status, detail := readiness(s)
+				render.Status(r, status)
+				render.JSON(w, r, detail)
+			}
+			mux.HandleFunc("GET /readyz", readyz)
+			// 兼容旧版本的 /health
+			mux.HandleFunc("GET /health", readyz)
+
+			registerUpdate(ctx, mux, s, updateAuth{
+				token:  flagOrEnv(c, "update-token", "IP2REGION_UPDATE_TOKEN"),
+				secret: flagOrEnv(c, "update-secret", "IP2REGION_UPDATE_SECRET"),
+			})
+
+			shutdownTimeout, _ := c.Flags().GetDuration("shutdown-timeout")
+			gs, err := startGRPC(c, s, tlsConfig, keys, limiter, cancel)
+			if err != nil {
+				return err
+			}
+			if gs != nil {
+				defer stopGRPC(gs, shutdownTimeout)
+			}
+
+			srv := newServer(c, mux)
+			srv.TLSConfig = tlsConfig
+			return serve(ctx, srv, shutdownTimeout)
 		},
 	}
 
 	c.Flags().StringP("listen", "l", ":3824", "监听地址")
 	c.Flags().StringP("type", "t", "xdb", "数据库类型, xdb, mmdb")
+	bindServerFlags(c)
+	bindTLSFlags(c)
+	bindRateLimitFlags(c)
+	bindAPIKeyFlags(c)
+	bindGRPCFlags(c)
+	c.Flags().Int("batch-max", 1000, "批量查询单次请求最多的IP数量")
+	c.Flags().StringSlice("trusted-proxies", nil, "受信任的反向代理地址或网段, 只有来自这些地址的请求才读取 Forwarded, X-Forwarded-For 头")
+	c.Flags().Bool("cloudflare", false, "读取受信任代理转发的 CF-Connecting-IP 头, 需要将 Cloudflare 的网段加入 --trusted-proxies")
+	c.Flags().String("update-token", "", "调用 /update 接口的 Bearer 令牌, 也可以通过环境变量 IP2REGION_UPDATE_TOKEN 设置")
+	c.Flags().String("update-secret", "", "调用 /update 接口的 HMAC-SHA256 签名密钥, 也可以通过环境变量 IP2REGION_UPDATE_SECRET 设置")
 
 	return c
 }
 
+// 探针路径, 不校验 API Key, 不限流
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true, "/health": true}
+
+// readiness 地址库已打开(不在打开或更新切换中)且未超过最长使用时间时就绪
+func readiness(s ip2region.Provider) (status int, detail render.M) {
+	checks := render.M{}
+	detail = render.M{"status": "ready", "checks": checks}
+	status = http.StatusOK
+
+	info, err := s.Info()
+	if err != nil {
+		checks["database"] = err.Error()
+		detail["status"] = "not_ready"
+		status = http.StatusServiceUnavailable
+		return
+	}
+	checks["database"] = "ok"
+
+	detail["type"] = info.Type
+	detail["build_time"] = info.BuildTime
+	detail["age"] = info.Age().String()
+
+	if info.Stale {
+		checks["fresh"] = "database is older than max-age"
+		detail["status"] = "not_ready"
+		status = http.StatusServiceUnavailable
+	} else {
+		checks["fresh"] = "ok"
+	}
+	return
+}
+
+// errStatus 根据错误类型返回 HTTP 状态码
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, ip2region.ErrInvalidIP):
+		return http.StatusBadRequest
+	case errors.Is(err, ip2region.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ip2region.ErrClosed), errors.Is(err, ip2region.ErrDatabaseMissing), errors.Is(err, ip2region.ErrCorruptDatabase):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ip2region.ErrReadOnly), errors.Is(err, ip2region.ErrOffline):
+		return http.StatusForbidden
+	case errors.Is(err, ip2region.ErrDownload):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func webErr(w http.ResponseWriter, r *http.Request, err error, status int) {
 	render.Status(r, status)
 	render.Respond(w, r, render.M{"err": err.Error()})
 }
 
+// flagOrEnv 返回参数值, 参数未设置时返回环境变量的值
+func flagOrEnv(c *cobra.Command, name, env string) string {
+	if v, _ := c.Flags().GetString(name); v != "" {
+		return v
+	}
+	return os.Getenv(env)
+}
+
+// clientIP 客户端地址
+func clientIP(r *http.Request) string {
+	if ip, _, _ := net.SplitHostPort(r.RemoteAddr); ip != "" {
+		return ip
+	}
+	return r.RemoteAddr
+}
+
+// webRespond 按 ?format=json|text 或 Accept 头返回 JSON 或纯文本, 都未指定时 curl 返回纯文本
 func webRespond(w http.ResponseWriter, r *http.Request, data any, dataStr string, status int) {
-	if userAgent := r.Header.Get("user-agent"); userAgent == "" || strings.Contains(userAgent, "curl") {
+	format := requestFormat(r)
+	if format == "" {
+		if userAgent := r.Header.Get("user-agent"); userAgent == "" || strings.Contains(userAgent, "curl") {
+			format = formatText
+		}
+	}
+
+	if format == formatText {
 		render.Status(r, status)
 		render.PlainText(w, r, dataStr)
 	} else {
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// 签名请求允许的时间偏差
+const signatureMaxSkew = 5 * time.Minute
+
+// 保留的更新任务数量
+const maxUpdateJobs = 20
+
+// updateAuth /update 接口的认证方式, 两者可以同时配置, 满足其一即可
+type updateAuth struct {
+	token  string // Authorization: Bearer <token>
+	secret string // X-Signature: sha256=hex(hmac_sha256(secret, X-Timestamp + "\n" + method + "\n" + path))
+}
+
+func (a updateAuth) enabled() bool {
+	return a.token != "" || a.secret != ""
+}
+
+func (a updateAuth) verify(r *http.Request) bool {
+	if a.token != "" {
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(a.token)) == 1 {
+				return true
+			}
+		}
+	}
+
+	if a.secret != "" {
+		sig, ok := strings.CutPrefix(r.Header.Get("X-Signature"), "sha256=")
+		if !ok {
+			return false
+		}
+
+		ts, err := strconv.ParseInt(r.Header.Get("X-Timestamp"), 10, 64)
+		if err != nil {
+			return false
+		}
+		if skew := time.Since(time.Unix(ts, 0)); skew > signatureMaxSkew || skew < -signatureMaxSkew {
+			return false
+		}
+
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			return false
+		}
+		return hmac.Equal(got, signUpdate(a.secret, r.Header.Get("X-Timestamp"), r.Method, r.URL.Path))
+	}
+	return false
+}
+
+func signUpdate(secret, ts, method, path string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "\n" + method + "\n" + path))
+	return mac.Sum(nil)
+}
+
+// updateJob 后台更新任务
+type updateJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"` // running, succeeded, failed
+	Err        string     `json:"err,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// updateJobs 更新任务列表, 同一时间只允许一个任务运行
+type updateJobs struct {
+	mu      sync.Mutex
+	jobs    []*updateJob
+	running *updateJob
+}
+
+var errUpdateRunning = errors.New("已有更新任务正在运行")
+
+func (u *updateJobs) start(ctx context.Context, s ip2region.Provider) (job updateJob, err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.running != nil {
+		return *u.running, errUpdateRunning
+	}
+
+	var id [8]byte
+	rand.Read(id[:])
+
+	j := &updateJob{ID: hex.EncodeToString(id[:]), Status: "running", StartedAt: time.Now()}
+	u.running = j
+	if u.jobs = append(u.jobs, j); len(u.jobs) > maxUpdateJobs {
+		u.jobs = u.jobs[len(u.jobs)-maxUpdateJobs:]
+	}
+
+	go func() {
+		slog.Info("开始更新数据库", "job", j.ID)
+		err := s.Update(ctx)
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		now := time.Now()
+		j.FinishedAt = &now
+		if j.Status = "succeeded"; err != nil {
+			j.Status, j.Err = "failed", err.Error()
+			slog.Warn("数据库更新失败", "job", j.ID, "err", err)
+		} else {
+			slog.Info("数据库更新完成", "job", j.ID, "耗时", now.Sub(j.StartedAt).String())
+		}
+		u.running = nil
+	}()
+
+	return *j, nil
+}
+
+func (u *updateJobs) get(id string) (job updateJob, ok bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, j := range u.jobs {
+		if j.ID == id || id == "latest" && j == u.jobs[len(u.jobs)-1] {
+			return *j, true
+		}
+	}
+	return
+}
+
+// registerUpdate 注册更新接口, POST /update 创建后台更新任务, GET /update/{id} 查询任务状态, id 为 latest 时返回最近的任务
+func registerUpdate(ctx context.Context, mux *chi.Mux, s ip2region.Provider, auth updateAuth) {
+	if !auth.enabled() {
+		slog.Info("未配置 --update-token 或 --update-secret, 已禁用 /update 接口")
+	}
+
+	jobs := &updateJobs{}
+
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if !auth.enabled() {
+			webErr(w, r, errors.New("更新接口未启用"), http.StatusForbidden)
+			return false
+		}
+		if !auth.verify(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ip2region"`)
+			webErr(w, r, errors.New("认证失败"), http.StatusUnauthorized)
+			return false
+		}
+		return true
+	}
+
+	mux.HandleFunc("POST /update", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+
+		job, err := jobs.start(ctx, s)
+		if err != nil {
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, render.M{"err": err.Error(), "job": job})
+			return
+		}
+
+		w.Header().Set("Location", "/update/"+job.ID)
+		render.Status(r, http.StatusAccepted)
+		render.JSON(w, r, job)
+	})
+
+	mux.HandleFunc("GET /update/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+
+		job, ok := jobs.get(chi.URLParam(r, "id"))
+		if !ok {
+			webErr(w, r, errors.New("更新任务不存在"), http.StatusNotFound)
+			return
+		}
+		render.JSON(w, r, job)
+	})
+}
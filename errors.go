@@ -0,0 +1,58 @@
+package ip2region
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cnk3x/ip2region/pkg/httpio"
+)
+
+var (
+	// ErrInvalidIP 无效的IP地址
+	ErrInvalidIP = errors.New("invalid ip address")
+	// ErrNotFound 地址库中没有该IP的记录
+	ErrNotFound = errors.New("ip not found")
+	// ErrClosed 地址库未打开或已关闭
+	ErrClosed = errors.New("database closed")
+	// ErrCorruptDatabase 地址库文件损坏或格式错误
+	ErrCorruptDatabase = errors.New("corrupt database")
+	// ErrDownload 地址库下载失败, 具体信息见 DownloadError
+	ErrDownload = errors.New("download failed")
+	// ErrDatabaseMissing 地址库文件不存在且不允许下载
+	ErrDatabaseMissing = errors.New("database missing")
+	// ErrOffline 离线模式下禁止下载地址库
+	ErrOffline = errors.New("download disabled in offline mode")
+	// ErrReadOnly 地址库来自只读数据源, 无法更新
+	ErrReadOnly = errors.New("read-only database source")
+)
+
+// DownloadError 地址库下载失败, errors.Is(err, ErrDownload) 为 true
+type DownloadError struct {
+	URL        string
+	StatusCode int // HTTP 状态码, 非 HTTP 状态错误时为 0
+	Err        error
+}
+
+// NewDownloadError 包装下载 url 时发生的错误, err 为 nil 时返回 nil
+func NewDownloadError(url string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	e := &DownloadError{URL: url, Err: err}
+	if se := (*httpio.StatusError)(nil); errors.As(err, &se) {
+		e.StatusCode = se.StatusCode
+	}
+	return e
+}
+
+func (e *DownloadError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("download %s: status %d: %v", e.URL, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("download %s: %v", e.URL, e.Err)
+}
+
+func (e *DownloadError) Unwrap() error { return e.Err }
+
+func (e *DownloadError) Is(target error) bool { return target == ErrDownload }
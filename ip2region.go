@@ -4,11 +4,16 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cnk3x/ip2region/pkg/fileio"
 )
 
 type Provider interface {
 	Search(ctx context.Context, ip string, langs ...string) (*Result, error)
 	Update(ctx context.Context) error
+	Info() (*Info, error)
 	Close() error
 }
 
@@ -19,12 +24,14 @@ type Result struct {
 	Subdivision Name   `json:"subdivision,omitempty"`
 	City        Name   `json:"city,omitempty"`
 	ISP         string `json:"isp,omitempty"`
+	Reserved    bool   `json:"reserved,omitempty"` // 是否为特殊用途地址, 此时地区信息为空
+	Kind        Kind   `json:"kind,omitempty"`
 }
 
 type Name struct {
 	Name string `json:"name,omitempty"`
-	Code string `json:"code,omitempty"`
-	ID   uint   `json:"id,omitempty"`
+	Code string `json:"code,omitempty"` // 国家为 ISO 3166-1 alpha-2 代码, 省级行政区为 ISO 3166-2 代码, 如 CN, CN-GD
+	ID   uint   `json:"id,omitempty"`   // GeoNames ID
 }
 
 func NewName(name, code string, id uint) Name {
@@ -79,6 +86,11 @@ func (r Result) InfoText() string {
 		w.WriteString(", ")
 	}
 
+	if r.Reserved {
+		w.WriteString(string(r.Kind))
+		w.WriteString(", ")
+	}
+
 	if w.Len() > 2 {
 		w.Truncate(w.Len() - 2)
 	}
@@ -89,3 +101,58 @@ func (r Result) InfoText() string {
 func (r Result) String() string {
 	return fmt.Sprintf("%15s: %s", r.IP, r.InfoText())
 }
+
+// Info 地址库信息
+type Info struct {
+	Type      string         `json:"type"`
+	File      string         `json:"file,omitempty"`
+	Size      int64          `json:"size,omitempty"`
+	ModTime   time.Time      `json:"mod_time"`
+	BuildTime time.Time      `json:"build_time"`
+	Stale     bool           `json:"stale"`
+	Meta      map[string]any `json:"meta,omitempty"`
+}
+
+func (i Info) String() string {
+	var w bytes.Buffer
+
+	fmt.Fprintf(&w, "%-16s %s\n", "type:", i.Type)
+	if i.File != "" {
+		fmt.Fprintf(&w, "%-16s %s\n", "file:", i.File)
+	}
+	if i.Size > 0 {
+		fmt.Fprintf(&w, "%-16s %s\n", "size:", fileio.HumanBytes(i.Size))
+	}
+	if !i.ModTime.IsZero() {
+		fmt.Fprintf(&w, "%-16s %s\n", "mod_time:", i.ModTime.Format(time.RFC3339))
+	}
+	if !i.BuildTime.IsZero() {
+		fmt.Fprintf(&w, "%-16s %s\n", "build_time:", i.BuildTime.Format(time.RFC3339))
+		fmt.Fprintf(&w, "%-16s %s\n", "age:", fileio.HumanDuration(i.Age()))
+	}
+	fmt.Fprintf(&w, "%-16s %t\n", "stale:", i.Stale)
+
+	keys := make([]string, 0, len(i.Meta))
+	for k := range i.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&w, "%-16s %v\n", k+":", i.Meta[k])
+	}
+
+	return w.String()
+}
+
+// Age 地址库自构建以来的时长, 构建时间未知时返回 0
+func (i Info) Age() time.Duration {
+	if i.BuildTime.IsZero() || i.BuildTime.Unix() <= 0 {
+		return 0
+	}
+	return time.Since(i.BuildTime)
+}
+
+// Expired 地址库是否超过 maxAge, maxAge <= 0 表示不限制
+func (i Info) Expired(maxAge time.Duration) bool {
+	return maxAge > 0 && i.Age() > maxAge
+}
@@ -0,0 +1,53 @@
+package ip2region
+
+import (
+	"errors"
+	"time"
+)
+
+// Metrics 监控指标回调, 由使用者实现并接入自己的监控系统, 各方法会被并发调用。
+// 嵌入 NopMetrics 可以只实现需要的方法。
+type Metrics interface {
+	// ObserveLookup 一次查询完成, err 为查询错误, 可用 ErrorType 转换为标签
+	ObserveLookup(provider string, d time.Duration, err error)
+	// ObserveIO 一次查询读取地址库文件的次数, 只有从文件查询的 Provider 会调用
+	ObserveIO(provider string, reads int)
+	// ObserveCache 一次查询是否命中结果缓存, 只有缓存查询结果的 Provider(如 remote) 会调用
+	ObserveCache(provider string, hit bool)
+	// ObserveUpdate 一次更新完成
+	ObserveUpdate(provider string, d time.Duration, err error)
+}
+
+// NopMetrics 不记录任何指标
+type NopMetrics struct{}
+
+func (NopMetrics) ObserveLookup(string, time.Duration, error) {}
+func (NopMetrics) ObserveIO(string, int)                      {}
+func (NopMetrics) ObserveCache(string, bool)                  {}
+func (NopMetrics) ObserveUpdate(string, time.Duration, error) {}
+
+// ErrorType 返回错误类型的简短名称, 用作监控指标的标签, err 为 nil 时返回空字符串
+func ErrorType(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrInvalidIP):
+		return "invalid_ip"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrClosed):
+		return "closed"
+	case errors.Is(err, ErrCorruptDatabase):
+		return "corrupt_database"
+	case errors.Is(err, ErrDownload):
+		return "download"
+	case errors.Is(err, ErrDatabaseMissing):
+		return "database_missing"
+	case errors.Is(err, ErrOffline):
+		return "offline"
+	case errors.Is(err, ErrReadOnly):
+		return "read_only"
+	default:
+		return "other"
+	}
+}
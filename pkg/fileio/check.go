@@ -2,6 +2,7 @@ package fileio
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 )
 
@@ -18,7 +19,7 @@ func CheckExist(filePath string, onNonExist func() error) (err error) {
 	}
 
 	if !stat.Mode().IsRegular() {
-		err = fmt.Errorf("错误的地址库文件路径: %s", filePath)
+		err = fmt.Errorf("错误的地址库文件路径: %s: %w", filePath, fs.ErrInvalid)
 	}
 	return
 }
@@ -0,0 +1,68 @@
+package fileio
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 锁被其他进程持有时重试的间隔
+const lockRetryInterval = 100 * time.Millisecond
+
+// LockFile 获取 filePath 的跨进程排他锁(filePath.lock), 锁被其他进程持有时每隔 100ms 重试,
+// 直到获取成功或 ctx 结束, 返回释放锁的函数
+func LockFile(ctx context.Context, filePath string) (unlock func() error, err error) {
+	if err = os.MkdirAll(filepath.Dir(filePath), 0777); err != nil {
+		return
+	}
+
+	var f *os.File
+	if f, err = os.OpenFile(filePath+".lock", os.O_RDWR|os.O_CREATE, 0666); err != nil {
+		return
+	}
+
+	for {
+		var ok bool
+		if ok, err = tryLockFile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", f.Name(), err)
+		}
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", f.Name(), context.Cause(ctx))
+		case <-time.After(lockRetryInterval):
+		}
+	}
+
+	unlock = func() (err error) {
+		err = unlockFile(f)
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+		return
+	}
+	return
+}
+
+// Changed 判断 filePath 相对于 old 是否已被替换或修改, old 为 nil 时文件存在即视为已修改
+func Changed(old fs.FileInfo, filePath string) bool {
+	cur, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+
+	return !sameState(old, cur)
+}
+
+// tempName 以进程号和时间生成唯一的临时文件名, 避免多个进程同时保存时互相覆盖
+func tempName(filePath string) string {
+	return fmt.Sprintf("%s.%d-%d.savetmp", filePath, os.Getpid(), time.Now().UnixNano())
+}
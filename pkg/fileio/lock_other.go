@@ -0,0 +1,10 @@
+//go:build !unix
+
+package fileio
+
+import "os"
+
+// 非 unix 平台暂不支持 flock, 仅保证锁文件存在, 不提供跨进程互斥
+
+func tryLockFile(*os.File) (bool, error) { return true, nil }
+func unlockFile(*os.File) error          { return nil }
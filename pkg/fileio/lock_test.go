@@ -0,0 +1,55 @@
+//go:build unix
+
+package fileio
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "db")
+
+	unlock, err := LockFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 锁被持有时等待到 ctx 结束
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err = LockFile(ctx, file); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LockFile() err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Errorf("LockFile() returned after %v, want after ctx deadline", d)
+	}
+
+	// 锁释放后重试成功
+	done := make(chan error, 1)
+	go func() {
+		unlock2, err := LockFile(context.Background(), file)
+		if err == nil {
+			err = unlock2()
+		}
+		done <- err
+	}()
+
+	time.Sleep(150 * time.Millisecond)
+	if err = unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("LockFile() did not acquire the released lock")
+	}
+}
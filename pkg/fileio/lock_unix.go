@@ -0,0 +1,28 @@
+//go:build unix
+
+package fileio
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFile 以非阻塞方式获取排他锁, 锁被其他进程持有时返回 false
+func tryLockFile(f *os.File) (ok bool, err error) {
+	for {
+		switch err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err {
+		case nil:
+			return true, nil
+		case syscall.EWOULDBLOCK:
+			return false, nil
+		case syscall.EINTR:
+			continue
+		default:
+			return false, err
+		}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -1,6 +1,7 @@
 package fileio
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -10,19 +11,31 @@ import (
 	"sync/atomic"
 )
 
+var (
+	// ErrValidate 写入的文件未通过 Validate 校验, 目标文件没有被替换
+	ErrValidate = errors.New("validation failed")
+	// ErrWrite 读取源数据或写入文件失败, 例如下载中断
+	ErrWrite = errors.New("write failed")
+)
+
 type SaveOptions struct {
 	Overwrite   bool         `json:"overwrite,omitempty"`
 	Mode        fs.FileMode  `json:"mode,omitempty"`
 	TotalBytes  int64        `json:"total_bytes,omitempty"`
 	UseTempFile bool         `json:"use_temp_file,omitempty"`
+	Versions    int          `json:"versions,omitempty"`
 	Progress    ProgressHook `json:"-"`
 	BeforeSave  []SaveHook   `json:"-"`
 	AfterSave   []SaveHook   `json:"-"`
+	Validate    ValidateHook `json:"-"`
 }
 
 type ProgressHook func(cur, total int64)
 type SaveHook func() error
 
+// ValidateHook 校验已写入的文件, filePath 为临时文件或版本文件的路径
+type ValidateHook func(filePath string) error
+
 func (options *SaveOptions) With(opts ...SaveOption) *SaveOptions {
 	for _, opt := range opts {
 		opt(options)
@@ -40,6 +53,9 @@ func Progress(report ProgressHook) SaveOption {
 }
 func TotalBytes(total int64) SaveOption { return func(opts *SaveOptions) { opts.TotalBytes = total } }
 
+// KeepVersions 以版本方式保存, 目标文件为指向最新版本的符号链接, 最多保留 n 个版本, n <= 0 时直接覆盖目标文件
+func KeepVersions(n int) SaveOption { return func(opts *SaveOptions) { opts.Versions = n } }
+
 func BeforeSave(before SaveHook) SaveOption {
 	return func(opts *SaveOptions) { opts.BeforeSave = append(opts.BeforeSave, before) }
 }
@@ -48,6 +64,22 @@ func AfterSave(after SaveHook) SaveOption {
 	return func(opts *SaveOptions) { opts.AfterSave = append(opts.AfterSave, after) }
 }
 
+// Validate 在替换目标文件前校验写入的内容, 校验失败时不替换目标文件, 也不执行 BeforeSave/AfterSave 钩子。
+// 使用 UseTempFile 或 KeepVersions 时才能在替换前校验, 否则在写入目标文件后校验
+func Validate(validate ValidateHook) SaveOption {
+	return func(opts *SaveOptions) { opts.Validate = validate }
+}
+
+// validate 使用 Validate 校验 filePath, 失败时返回的错误包装了 ErrValidate
+func (options *SaveOptions) validate(filePath string) (err error) {
+	if options.Validate != nil {
+		if err = options.Validate(filePath); err != nil {
+			err = fmt.Errorf("%w: %w", ErrValidate, err)
+		}
+	}
+	return
+}
+
 func Save(src io.Reader, filePath string, opts ...SaveOption) (err error) {
 	options := (&SaveOptions{Mode: 0666}).With(opts...)
 
@@ -63,12 +95,12 @@ func Save(src io.Reader, filePath string, opts ...SaveOption) (err error) {
 			}
 		} else {
 			if !options.Overwrite {
-				err = fmt.Errorf("target %s already exists", filePath)
+				err = fmt.Errorf("target %s: %w", filePath, fs.ErrExist)
 				return
 			}
 
 			if !stat.Mode().IsRegular() && stat.Mode()&fs.ModeSymlink == 0 {
-				err = fmt.Errorf("file %s is not a regular file, can not overwrite", filePath)
+				err = fmt.Errorf("file %s is not a regular file, can not overwrite: %w", filePath, fs.ErrInvalid)
 				return
 			}
 
@@ -127,7 +159,9 @@ func Save(src io.Reader, filePath string, opts ...SaveOption) (err error) {
 			} else {
 				w = f
 			}
-			_, err = io.Copy(w, src)
+			if _, err = io.Copy(w, src); err != nil {
+				err = fmt.Errorf("%w: %w", ErrWrite, err)
+			}
 			return
 		}
 	}
@@ -166,14 +200,40 @@ func Save(src io.Reader, filePath string, opts ...SaveOption) (err error) {
 		return
 	}
 
+	if options.Versions > 0 {
+		if err = handleCheck(filePath, false); err != nil {
+			return
+		}
+
+		var name string
+		if name, err = saveVersion(src, filePath, options.Mode, copyIt); err != nil {
+			return
+		}
+
+		if err = options.validate(filepath.Join(VersionDir(filePath), name)); err != nil {
+			_ = os.Remove(filepath.Join(VersionDir(filePath), name))
+			return
+		}
+
+		if err = doSave(func() error { return activate(filePath, name) }); err != nil {
+			return
+		}
+
+		return pruneVersions(filePath, options.Versions)
+	}
+
 	if options.UseTempFile {
-		tempFilePath := filePath + ".savetmp"
+		tempFilePath := tempName(filePath)
 		defer os.Remove(tempFilePath)
 
 		if err = handleFileOpen(tempFilePath, createFlag(true), options.Mode, copyIt(src)); err != nil {
 			return
 		}
 
+		if err = options.validate(tempFilePath); err != nil {
+			return
+		}
+
 		return doSave(func() error { return handleRename(tempFilePath, filePath) })
 	}
 
@@ -181,8 +241,11 @@ func Save(src io.Reader, filePath string, opts ...SaveOption) (err error) {
 		return
 	}
 
-	return doSave(func() error {
-		return handleFileOpen(filePath, createFlag(options.Overwrite), options.Mode, copyIt(src))
+	return doSave(func() (err error) {
+		if err = handleFileOpen(filePath, createFlag(options.Overwrite), options.Mode, copyIt(src)); err != nil {
+			return
+		}
+		return options.validate(filePath)
 	})
 }
 
@@ -0,0 +1,119 @@
+package fileio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var errInvalid = errors.New("invalid")
+
+// validateContent 内容以 ok 开头时校验通过
+func validateContent(filePath string) error {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(string(b), "ok") {
+		return errInvalid
+	}
+	return nil
+}
+
+func TestSaveValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []SaveOption
+	}{
+		{"temp file", []SaveOption{UseTempFile}},
+		{"versions", []SaveOption{KeepVersions(3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(t.TempDir(), "db")
+
+			var before, after int
+			opts := append([]SaveOption{
+				Overwrite,
+				Validate(validateContent),
+				BeforeSave(func() error { before++; return nil }),
+				AfterSave(func() error { after++; return nil }),
+			}, tt.opts...)
+
+			if err := Save(strings.NewReader("ok v1"), target, opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := Save(strings.NewReader("corrupt"), target, opts...); !errors.Is(err, errInvalid) || !errors.Is(err, ErrValidate) {
+				t.Fatalf("Save(corrupt) err = %v, want %v and %v", err, errInvalid, ErrValidate)
+			}
+
+			if b, _ := os.ReadFile(target); string(b) != "ok v1" {
+				t.Errorf("target = %q, want %q", b, "ok v1")
+			}
+			if before != 1 || after != 1 {
+				t.Errorf("hooks called %d, %d times, want 1, 1", before, after)
+			}
+
+			// 校验失败的内容不会留下临时文件或版本
+			entries, _ := os.ReadDir(filepath.Dir(target))
+			for _, e := range entries {
+				if strings.Contains(e.Name(), ".savetmp") {
+					t.Errorf("temp file left: %s", e.Name())
+				}
+			}
+			versions, _ := ListVersions(target)
+			if len(versions) > 1 {
+				t.Errorf("got %d versions, want at most 1", len(versions))
+			}
+		})
+	}
+}
+
+func TestActivateValidate(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "db")
+	for _, content := range []string{"corrupt", "ok v2"} {
+		if err := Save(strings.NewReader(content), target, Overwrite, KeepVersions(3)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var after int
+	_, err := Rollback(target, Validate(validateContent), AfterSave(func() error { after++; return nil }))
+	if !errors.Is(err, errInvalid) || !errors.Is(err, ErrValidate) {
+		t.Fatalf("Rollback err = %v, want %v and %v", err, errInvalid, ErrValidate)
+	}
+	if b, _ := os.ReadFile(target); string(b) != "ok v2" || after != 0 {
+		t.Errorf("target = %q, after = %d, want %q, 0", b, after, "ok v2")
+	}
+}
+
+func TestSaveWriteError(t *testing.T) {
+	errRead := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		opts []SaveOption
+	}{
+		{"direct", nil},
+		{"temp file", []SaveOption{UseTempFile}},
+		{"versions", []SaveOption{KeepVersions(3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(t.TempDir(), "db")
+			src := io.MultiReader(strings.NewReader("ok partial"), iotest.ErrReader(errRead))
+
+			err := Save(src, target, append([]SaveOption{Overwrite}, tt.opts...)...)
+			if !errors.Is(err, ErrWrite) || !errors.Is(err, errRead) {
+				t.Errorf("Save() err = %v, want %v and %v", err, ErrWrite, errRead)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package fileio
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version 数据文件的一个历史版本
+type Version struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Active  bool      `json:"active"`
+}
+
+// ErrNoVersion 没有可用的历史版本
+var ErrNoVersion = errors.New("no version to activate")
+
+// VersionDir 数据文件的版本目录, 例如 ip2region.xdb 的版本保存在 ip2region.xdb.versions 中
+func VersionDir(filePath string) string {
+	return filePath + ".versions"
+}
+
+// ListVersions 列出数据文件的所有版本, 按保存时间从旧到新排序
+func ListVersions(filePath string) (versions []Version, err error) {
+	dir := VersionDir(filePath)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	active, _ := os.Readlink(filePath)
+	active = filepath.Base(active)
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		fi, e := entry.Info()
+		if e != nil {
+			continue
+		}
+
+		versions = append(versions, Version{
+			Name:    entry.Name(),
+			Path:    filepath.Join(dir, entry.Name()),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+			Active:  entry.Name() == active,
+		})
+	}
+
+	// 版本名以保存时间开头, 按名称排序即为时间顺序
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Name < versions[j].Name })
+	return
+}
+
+// Activate 将数据文件指向指定版本, 选项中的 Validate 在切换前校验版本文件, BeforeSave/AfterSave 钩子在切换前后执行
+func Activate(filePath, name string, opts ...SaveOption) (err error) {
+	options := (&SaveOptions{}).With(opts...)
+
+	versionPath := filepath.Join(VersionDir(filePath), name)
+	if _, err = os.Stat(versionPath); err != nil {
+		return
+	}
+
+	if err = options.validate(versionPath); err != nil {
+		return
+	}
+
+	for _, f := range options.BeforeSave {
+		if err = f(); err != nil {
+			return
+		}
+	}
+
+	if err = activate(filePath, name); err != nil {
+		return
+	}
+
+	for _, f := range options.AfterSave {
+		if err = f(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Rollback 将数据文件切换到当前版本的上一个版本
+func Rollback(filePath string, opts ...SaveOption) (prev Version, err error) {
+	var versions []Version
+	if versions, err = ListVersions(filePath); err != nil {
+		return
+	}
+
+	cur := -1
+	for i, v := range versions {
+		if v.Active {
+			cur = i
+			break
+		}
+	}
+
+	if cur <= 0 {
+		err = fmt.Errorf("%s: %w", filePath, ErrNoVersion)
+		return
+	}
+
+	prev = versions[cur-1]
+	err = Activate(filePath, prev.Name, opts...)
+	return
+}
+
+// saveVersion 将 src 保存为版本目录中的一个新版本, 返回版本名称
+func saveVersion(src io.Reader, filePath string, mode fs.FileMode, copyIt func(io.Reader) func(*os.File) error) (name string, err error) {
+	dir := VersionDir(filePath)
+	if err = os.MkdirAll(dir, 0777); err != nil {
+		return
+	}
+
+	tempFilePath := tempName(dir + string(filepath.Separator))
+	defer os.Remove(tempFilePath)
+
+	var f *os.File
+	if f, err = os.OpenFile(tempFilePath, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode); err != nil {
+		return
+	}
+
+	h := sha256.New()
+	err = copyIt(io.TeeReader(src, h))(f)
+	if e := f.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		return
+	}
+
+	name = versionName(filePath, time.Now(), h)
+	err = os.Rename(tempFilePath, filepath.Join(dir, name))
+	return
+}
+
+// activate 原子地将 filePath 替换为指向版本文件的符号链接, 已存在的普通文件会先被收入版本目录。
+// 先创建符号链接, 不支持符号链接(如没有权限的 Windows)时不改动 filePath
+func activate(filePath, name string) (err error) {
+	dir := VersionDir(filePath)
+
+	linkPath := filePath + ".linktmp"
+	_ = os.Remove(linkPath)
+	if err = os.Symlink(filepath.Join(filepath.Base(dir), name), linkPath); err != nil {
+		return fmt.Errorf("keeping versions requires symlink support: %w", err)
+	}
+
+	if stat, e := os.Lstat(filePath); e == nil && stat.Mode().IsRegular() {
+		if err = archive(filePath, stat.ModTime()); err != nil {
+			_ = os.Remove(linkPath)
+			return
+		}
+	}
+
+	if err = os.Rename(linkPath, filePath); err != nil {
+		_ = os.Remove(linkPath)
+	}
+	return
+}
+
+// archive 将未版本化的普通数据文件移入版本目录
+func archive(filePath string, modTime time.Time) (err error) {
+	var f *os.File
+	if f, err = os.Open(filePath); err != nil {
+		return
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(VersionDir(filePath), 0777); err != nil {
+		return
+	}
+	return os.Rename(filePath, filepath.Join(VersionDir(filePath), versionName(filePath, modTime, h)))
+}
+
+// pruneVersions 只保留最新的 keep 个版本, 当前使用的版本不会被删除
+func pruneVersions(filePath string, keep int) (err error) {
+	var versions []Version
+	if versions, err = ListVersions(filePath); err != nil {
+		return
+	}
+
+	for i := 0; i < len(versions)-keep; i++ {
+		if versions[i].Active {
+			continue
+		}
+		if e := os.Remove(versions[i].Path); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+func versionName(filePath string, t time.Time, h hash.Hash) string {
+	return t.Format("20060102150405.000000") + "-" + hex.EncodeToString(h.Sum(nil))[:8] + filepath.Ext(filePath)
+}
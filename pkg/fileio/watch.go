@@ -0,0 +1,51 @@
+package fileio
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Watch 在后台每隔 interval 轮询一次 filePath, 文件被替换或修改后, 在连续两次轮询结果一致时(防抖)调用 onChange,
+// ctx 取消时停止。文件暂时不存在(例如正在被替换)时忽略本次轮询。
+func Watch(ctx context.Context, filePath string, interval time.Duration, onChange func()) {
+	last, _ := os.Stat(filePath)
+
+	go func() {
+		var pending fs.FileInfo
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			cur, err := os.Stat(filePath)
+			if err != nil {
+				continue
+			}
+
+			switch {
+			case pending != nil && sameState(pending, cur):
+				pending, last = nil, cur
+				onChange()
+			case !sameState(last, cur):
+				pending = cur
+			default:
+				pending = nil
+			}
+		}
+	}()
+}
+
+func sameState(a, b fs.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
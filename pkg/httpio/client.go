@@ -2,6 +2,7 @@ package httpio
 
 import (
 	"net/http"
+	"time"
 )
 
 type ClientOption func(*http.Client)
@@ -13,3 +14,13 @@ func BuildClient(opts ...ClientOption) (*http.Client, error) {
 	}
 	return client, nil
 }
+
+// Timeout 整个请求(包括读取响应)的超时时间
+func Timeout(d time.Duration) ClientOption {
+	return func(c *http.Client) { c.Timeout = d }
+}
+
+// Transport 使用 rt 发送请求, 多个请求共用同一个 rt 时可以复用连接
+func Transport(rt http.RoundTripper) ClientOption {
+	return func(c *http.Client) { c.Transport = rt }
+}
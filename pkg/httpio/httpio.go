@@ -2,9 +2,14 @@ package httpio
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 )
 
+// ErrRequest 请求没有得到响应, 例如无法连接或超时, 可以继续用 errors.Is 判断 context.Canceled 等原因
+var ErrRequest = errors.New("request failed")
+
 func New(url string, opts ...RequestOption) *Request {
 	r := &Request{requests: opts}
 
@@ -26,6 +31,11 @@ func (r *Request) With(opts ...RequestOption) *Request {
 	return r
 }
 
+func (r *Request) Client(opts ...ClientOption) *Request {
+	r.clients = append(r.clients, opts...)
+	return r
+}
+
 func (r *Request) Use(middlewares ...ResponseMiddleware) *Request {
 	r.middlewares = append(r.middlewares, middlewares...)
 	return r
@@ -51,6 +61,7 @@ func (r *Request) Do(ctx context.Context, process ResponseProcess) (err error) {
 	}
 
 	if resp, err = client.Do(req); err != nil {
+		err = fmt.Errorf("%w: %w", ErrRequest, err)
 		return
 	}
 
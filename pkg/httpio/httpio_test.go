@@ -0,0 +1,56 @@
+package httpio
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDoErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		url    string
+		want   []error
+		status int
+	}{
+		{"ok", context.Background(), srv.URL + "/ok", nil, 0},
+		{"status", context.Background(), srv.URL + "/missing", []error{ErrStatus}, http.StatusNotFound},
+		{"unreachable", context.Background(), closed.URL, []error{ErrRequest}, 0},
+		{"canceled", canceled, srv.URL + "/ok", []error{ErrRequest, context.Canceled}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.url).Use(StatusOK).Do(tt.ctx, func(*http.Response) error { return nil })
+			if tt.want == nil && err != nil {
+				t.Fatalf("Do() err = %v", err)
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("Do() err = %v, want %v", err, want)
+				}
+			}
+
+			var se *StatusError
+			if errors.As(err, &se) != (tt.status != 0) || se != nil && se.StatusCode != tt.status {
+				t.Errorf("Do() err = %v, want status %d", err, tt.status)
+			}
+		})
+	}
+}
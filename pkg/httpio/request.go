@@ -85,6 +85,10 @@ func BuildRequest(options ...RequestOption) (req *http.Request, err error) {
 	return
 }
 
+func Method(method string) RequestOption {
+	return func(ro *RequestOptions) { ro.Method = method }
+}
+
 func Headers(hdrs ...string) RequestOption {
 	return func(ro *RequestOptions) {
 		ro.Headers = append(ro.Headers, hdrs...)
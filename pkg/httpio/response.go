@@ -2,6 +2,7 @@ package httpio
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -51,11 +52,26 @@ func JSON(out any) ResponseProcess {
 	}
 }
 
+// ErrStatus 响应的状态码表示请求失败, 具体状态码见 StatusError
+var ErrStatus = errors.New("unexpected http status")
+
+// StatusError response status code error, errors.Is(err, ErrStatus) 为 true
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status code is not 200, %d", e.StatusCode)
+}
+
+func (e *StatusError) Is(target error) bool { return target == ErrStatus }
+
 // StatusOK check response status code
 func StatusOK(next ResponseProcess) ResponseProcess {
 	return func(resp *http.Response) (err error) {
 		if resp.StatusCode >= http.StatusBadRequest {
-			err = fmt.Errorf("http status code is not 200, %d", resp.StatusCode)
+			err = &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 		} else {
 			err = next(resp)
 		}
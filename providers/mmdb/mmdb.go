@@ -4,13 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
 	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/cnk3x/ip2region"
 	"github.com/cnk3x/ip2region/pkg/fileio"
 	"github.com/cnk3x/ip2region/pkg/httpio"
 	"github.com/oschwald/geoip2-golang"
+	"github.com/oschwald/maxminddb-golang"
 )
 
 var dbDownloadUrl = "https://raw.gitmirror.com/P3TERX/GeoLite.mmdb/download/GeoLite2-City.mmdb"
@@ -21,6 +28,12 @@ var dbDownloadUrl = "https://raw.gitmirror.com/P3TERX/GeoLite.mmdb/download/GeoL
 
 type Options struct {
 	DownloadUrl string
+	MaxAge      time.Duration // 地址库最长使用时间, 超过后在打开时自动更新, 0 表示不限制
+	Versions    int           // 保留的地址库版本数, 大于 0 时可通过 Rollback 回滚, 需要系统支持符号链接, 0 表示直接覆盖
+	Watch       time.Duration // 轮询地址库文件的间隔, 文件被替换后自动重新加载, 0 表示不监听
+	Offline     bool          // 离线模式, 不下载地址库, 文件不存在时返回 ip2region.ErrDatabaseMissing
+	UpdateFile  string        // 内存地址库的更新路径, 设置后 Update 下载到该路径, 且该文件存在时优先于内存地址库
+	Metrics     ip2region.Metrics
 }
 
 func Default() (p ip2region.Provider, err error) {
@@ -35,9 +48,16 @@ func Open(ctx context.Context, dbFile string, options *Options) (p ip2region.Pro
 		options.DownloadUrl = dbDownloadUrl
 	}
 
-	s := &Provider{dbUrl: options.DownloadUrl, dbFile: dbFile}
+	if options.Metrics == nil {
+		options.Metrics = ip2region.NopMetrics{}
+	}
+
+	s := &Provider{dbUrl: options.DownloadUrl, dbFile: dbFile, maxAge: options.MaxAge, keep: options.Versions, offline: options.Offline, metrics: options.Metrics}
 
 	if err = fileio.CheckExist(dbFile, func() (err error) {
+		if s.offline {
+			return fmt.Errorf("%w: %s", ip2region.ErrDatabaseMissing, dbFile)
+		}
 		slog.Info("地址库不存在，开始下载", "path", dbFile, "url", options.DownloadUrl)
 		err = s.Update(ctx)
 		return
@@ -45,51 +65,305 @@ func Open(ctx context.Context, dbFile string, options *Options) (p ip2region.Pro
 		return
 	}
 
-	return s, s.init()
+	if !s.loaded() {
+		if err = s.init(); err != nil {
+			return s, err
+		}
+	}
+
+	s.checkAge(ctx)
+
+	s.watch(options.Watch)
+	return s, nil
 }
 
 type Provider struct {
-	r      *geoip2.Reader
+	mu   sync.RWMutex
+	r    *geoip2.Reader
+	stat fs.FileInfo // 加载时的文件状态, 用于判断是否已被其他进程更新
+
 	dbUrl  string
 	dbFile string
+	maxAge time.Duration
+	keep   int
+	stop   context.CancelFunc
+
+	offline bool
+	buf     []byte // 内存中的地址库, dbFile 不存在时使用
+	metrics ip2region.Metrics
+}
+
+// OpenBytes 从内存打开地址库, 例如通过 go:embed 嵌入的地址库。
+// 未设置 Options.UpdateFile 时地址库只读, Update 返回 ip2region.ErrReadOnly。
+// Options.Versions 作用于 UpdateFile, 未设置 UpdateFile 时返回错误。
+func OpenBytes(b []byte, options *Options) (p ip2region.Provider, err error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if options.DownloadUrl == "" {
+		options.DownloadUrl = dbDownloadUrl
+	}
+
+	if options.Metrics == nil {
+		options.Metrics = ip2region.NopMetrics{}
+	}
+
+	if options.Versions > 0 && options.UpdateFile == "" {
+		return nil, errors.New("Options.Versions 需要同时设置 Options.UpdateFile")
+	}
+
+	s := &Provider{dbUrl: options.DownloadUrl, dbFile: options.UpdateFile, maxAge: options.MaxAge, keep: options.Versions, offline: options.Offline, buf: b, metrics: options.Metrics}
+	if err = s.init(); err != nil {
+		return
+	}
+
+	s.checkAge(context.Background())
+
+	s.watch(options.Watch)
+	return s, nil
 }
 
+// OpenReaderAt 从 r 读取大小为 size 的地址库, 其他同 OpenBytes。
+// maxminddb 只支持从内存或文件查询, 打开时一次读入内存, 之后不再使用 r
+func OpenReaderAt(r io.ReaderAt, size int64, options *Options) (p ip2region.Provider, err error) {
+	b := make([]byte, size)
+	if _, err = io.ReadFull(io.NewSectionReader(r, 0, size), b); err != nil {
+		return nil, fmt.Errorf("%w: 读取地址库失败: %w", ip2region.ErrCorruptDatabase, err)
+	}
+	return OpenBytes(b, options)
+}
+
+// OpenFS 从 fsys 中打开地址库, 例如 embed.FS, 其他同 OpenBytes
+func OpenFS(fsys fs.FS, name string, options *Options) (p ip2region.Provider, err error) {
+	var b []byte
+	if b, err = fs.ReadFile(fsys, name); err != nil {
+		return
+	}
+	return OpenBytes(b, options)
+}
+
+// init 打开并校验地址库, 成功后替换当前使用的地址库
 func (d *Provider) init() (err error) {
-	if d.r, err = geoip2.Open(d.dbFile); err != nil {
+	var (
+		r    *geoip2.Reader
+		stat fs.FileInfo
+	)
+
+	if r, stat, err = d.open(d.dbFile); err != nil {
 		return
 	}
+
+	d.mu.Lock()
+	old := d.r
+	d.r, d.stat = r, stat
+	d.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
 	return
 }
 
+// check 校验下载或回滚的地址库文件, 通过后才替换当前文件, 校验期间继续使用当前地址库
+func (d *Provider) check(path string) (err error) {
+	r, _, err := d.open(path)
+	if err == nil {
+		r.Close()
+	}
+	return
+}
+
+// open 打开地址库文件 path 并校验, path 不存在时使用内存中的地址库
+func (d *Provider) open(path string) (r *geoip2.Reader, stat fs.FileInfo, err error) {
+	if path != "" {
+		stat, _ = os.Stat(path)
+	}
+
+	// 错误信息中的地址库文件, 使用内存中的地址库时为空
+	src := path
+	if stat == nil && d.buf != nil {
+		src = ""
+		r, err = geoip2.FromBytes(d.buf)
+	} else {
+		r, err = geoip2.Open(path)
+	}
+	if err != nil {
+		var pe *fs.PathError
+		switch {
+		case errors.As(err, &pe):
+		case src != "":
+			err = fmt.Errorf("%w: %s: %w", ip2region.ErrCorruptDatabase, src, err)
+		default:
+			err = fmt.Errorf("%w: %w", ip2region.ErrCorruptDatabase, err)
+		}
+		return
+	}
+
+	if err = validate(r); err != nil {
+		r.Close()
+		r = nil
+		if src != "" {
+			err = fmt.Errorf("%s: %w", src, err)
+		}
+	}
+	return
+}
+
+// watch 每隔 interval 检查地址库文件, 被替换后自动重新加载
+func (d *Provider) watch(interval time.Duration) {
+	if interval <= 0 || d.dbFile == "" {
+		return
+	}
+
+	var ctx context.Context
+	ctx, d.stop = context.WithCancel(context.Background())
+	fileio.Watch(ctx, d.dbFile, interval, d.reload)
+}
+
+// reload 地址库文件被替换后重新加载, 新文件校验失败时继续使用旧地址库
+func (d *Provider) reload() {
+	d.mu.RLock()
+	stat := d.stat
+	d.mu.RUnlock()
+
+	if !fileio.Changed(stat, d.dbFile) {
+		return
+	}
+
+	if err := d.init(); err != nil {
+		slog.Warn("地址库重新加载失败，继续使用旧地址库", "path", d.dbFile, "err", err)
+		return
+	}
+	slog.Info("地址库已重新加载", "path", d.dbFile)
+}
+
+// validate 校验地址库元数据, 并试查询一次
+func validate(r *geoip2.Reader) (err error) {
+	if m := r.Metadata(); m.NodeCount == 0 {
+		return fmt.Errorf("%w: 无效的地址库: %s", ip2region.ErrCorruptDatabase, m.DatabaseType)
+	}
+
+	if _, err = r.City(net.IPv4(1, 1, 1, 1)); err != nil {
+		err = fmt.Errorf("%w: 地址库校验失败: %w", ip2region.ErrCorruptDatabase, err)
+	}
+	return
+}
+
+func (d *Provider) loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.r != nil
+}
+
 func (d *Provider) Update(ctx context.Context) (err error) {
-	return httpio.New(d.dbUrl).Use(httpio.StatusOK, httpio.Progress(consoleProgress)).
+	defer func(start time.Time) { d.metrics.ObserveUpdate("mmdb", time.Since(start), err) }(time.Now())
+
+	if d.dbFile == "" {
+		return ip2region.ErrReadOnly
+	}
+
+	if d.offline {
+		return ip2region.ErrOffline
+	}
+
+	var unlock func() error
+	if unlock, err = fileio.LockFile(ctx, d.dbFile); err != nil {
+		return
+	}
+	defer unlock()
+
+	d.mu.RLock()
+	stat := d.stat
+	d.mu.RUnlock()
+
+	// 等待锁期间其他进程可能已完成更新, 直接重新加载
+	if fileio.Changed(stat, d.dbFile) {
+		slog.Info("地址库已被其他进程更新，重新加载", "path", d.dbFile)
+		return d.init()
+	}
+
+	err = httpio.New(d.dbUrl).Use(httpio.StatusOK, httpio.Progress(consoleProgress)).
 		Do(ctx, httpio.Download(
 			d.dbFile,
 			fileio.UseTempFile,
 			fileio.Overwrite,
-			fileio.BeforeSave(d.Close),
+			fileio.KeepVersions(d.keep),
+			fileio.Validate(d.check),
 			fileio.AfterSave(d.init),
 		))
+	return ip2region.NewDownloadError(d.dbUrl, err)
+}
+
+// Rollback 切换到上一个版本的地址库并重新加载
+func (d *Provider) Rollback(ctx context.Context) (err error) {
+	if d.dbFile == "" {
+		return ip2region.ErrReadOnly
+	}
+
+	var unlock func() error
+	if unlock, err = fileio.LockFile(ctx, d.dbFile); err != nil {
+		return
+	}
+	defer unlock()
+
+	var prev fileio.Version
+	if prev, err = fileio.Rollback(d.dbFile, fileio.Validate(d.check), fileio.AfterSave(d.init)); err != nil {
+		return
+	}
+
+	slog.Info("地址库已回滚", "path", d.dbFile, "version", prev.Name)
+	return
 }
 
 func (d *Provider) Search(_ context.Context, ip string, langs ...string) (out *ip2region.Result, err error) {
+	defer func(start time.Time) { d.metrics.ObserveLookup("mmdb", time.Since(start), err) }(time.Now())
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var addr netip.Addr
+	if addr, err = ip2region.ParseAddr(ip); err != nil {
+		return
+	}
+
+	if out = ip2region.ReservedResult(addr); out != nil {
+		return
+	}
+
 	if d.r == nil {
-		err = errors.New("reader is nil")
+		err = ip2region.ErrClosed
+		return
+	}
+
+	if addr.Is6() && d.r.Metadata().IPVersion == 4 {
+		err = fmt.Errorf("%w: IPv4 地址库不支持 IPv6 地址 %s", ip2region.ErrNotFound, ip)
 		return
 	}
 
 	var r *geoip2.City
-	if r, err = d.r.City(net.ParseIP(ip)); err != nil {
+	if r, err = d.r.City(net.IP(addr.AsSlice())); err != nil {
+		err = lookupError(err)
 		return
 	}
 
-	out = &ip2region.Result{IP: ip}
+	if r.Continent.GeoNameID == 0 && r.Country.GeoNameID == 0 && r.RegisteredCountry.GeoNameID == 0 && r.City.GeoNameID == 0 {
+		err = fmt.Errorf("%w: %s", ip2region.ErrNotFound, ip)
+		return
+	}
+
+	out = &ip2region.Result{IP: addr.String(), Kind: ip2region.KindPublic}
 
 	out.Continent = getName(r.Continent.Names, r.Continent.Code, r.Continent.GeoNameID, langs...)
 	out.Country = getName(r.Country.Names, r.Country.IsoCode, r.Country.GeoNameID, langs...)
 
 	if len(r.Subdivisions) > 0 {
-		out.Subdivision = getName(r.Subdivisions[0].Names, r.Subdivisions[0].IsoCode, r.Subdivisions[0].GeoNameID, langs...)
+		// 补全为 ISO 3166-2 代码, 如 GD -> CN-GD, 与 xdb 的结果保持一致
+		code := r.Subdivisions[0].IsoCode
+		if code != "" && r.Country.IsoCode != "" {
+			code = r.Country.IsoCode + "-" + code
+		}
+		out.Subdivision = getName(r.Subdivisions[0].Names, code, r.Subdivisions[0].GeoNameID, langs...)
 	}
 
 	out.City = getName(r.City.Names, "", r.City.GeoNameID, langs...)
@@ -97,7 +371,84 @@ func (d *Provider) Search(_ context.Context, ip string, langs ...string) (out *i
 	return
 }
 
+// lookupError 地址库数据无法解码或类型不支持城市查询时为 ip2region.ErrCorruptDatabase, 其他错误原样返回
+func lookupError(err error) error {
+	var (
+		ide maxminddb.InvalidDatabaseError
+		ute maxminddb.UnmarshalTypeError
+		ime geoip2.InvalidMethodError
+	)
+	if errors.As(err, &ide) || errors.As(err, &ute) || errors.As(err, &ime) {
+		return fmt.Errorf("%w: %w", ip2region.ErrCorruptDatabase, err)
+	}
+	return err
+}
+
+func (d *Provider) Info() (info *ip2region.Info, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.r == nil {
+		err = ip2region.ErrClosed
+		return
+	}
+
+	m := d.r.Metadata()
+	info = &ip2region.Info{
+		Type:      "mmdb",
+		File:      d.dbFile,
+		BuildTime: time.Unix(int64(m.BuildEpoch), 0),
+		Meta: map[string]any{
+			"database_type": m.DatabaseType,
+			"description":   m.Description["en"],
+			"languages":     m.Languages,
+			"ip_version":    m.IPVersion,
+			"node_count":    m.NodeCount,
+			"record_size":   m.RecordSize,
+			"binary_format": fmt.Sprintf("%d.%d", m.BinaryFormatMajorVersion, m.BinaryFormatMinorVersion),
+		},
+	}
+	info.Stale = info.Expired(d.maxAge)
+
+	if d.stat == nil && d.buf != nil {
+		info.File = ""
+		info.Size = int64(len(d.buf))
+	} else if stat, e := os.Stat(d.dbFile); e == nil {
+		info.Size = stat.Size()
+		info.ModTime = stat.ModTime()
+	}
+	return
+}
+
+// checkAge 地址库超过 maxAge 时自动更新, 更新失败时继续使用旧地址库
+func (d *Provider) checkAge(ctx context.Context) {
+	info, err := d.Info()
+	if err != nil || !info.Stale {
+		return
+	}
+
+	if d.offline || d.dbFile == "" {
+		slog.Warn("地址库已过期", "path", d.dbFile, "build_time", info.BuildTime, "max_age", d.maxAge)
+		return
+	}
+
+	slog.Warn("地址库已过期，开始更新", "path", d.dbFile, "build_time", info.BuildTime, "max_age", d.maxAge)
+	if err = d.Update(ctx); err != nil {
+		slog.Warn("地址库更新失败，继续使用旧地址库", "path", d.dbFile, "err", err)
+	}
+}
+
 func (d *Provider) Close() (err error) {
+	if d.stop != nil {
+		d.stop()
+	}
+	return d.close()
+}
+
+func (d *Provider) close() (err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if d.r != nil {
 		err = d.r.Close()
 		d.r = nil
@@ -0,0 +1,148 @@
+package overrides
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cnk3x/ip2region"
+	"gopkg.in/yaml.v3"
+)
+
+// 自定义文件中的字段名
+//
+//	cidr, continent, continent_code, country, country_code, subdivision, subdivision_code, city, isp, kind
+//
+// cidr 为必填项, 也可以是单个IP地址; kind 为空时按地址自动判断, 否则必须是 ip2region.Kind 中的类型。
+type record = map[string]string
+
+type entry struct {
+	Prefix netip.Prefix
+	Result ip2region.Result
+}
+
+func loadFile(file string) (t *table, err error) {
+	var data []byte
+	if data, err = os.ReadFile(file); err != nil {
+		return
+	}
+
+	var records []record
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".csv":
+		records, err = parseCSV(data)
+	case ".json":
+		err = json.Unmarshal(data, &records)
+	case ".yaml", ".yml":
+		records, err = parseYAML(data)
+	default:
+		err = fmt.Errorf("不支持的自定义地址段文件格式: %s", ext)
+	}
+	if err != nil {
+		err = fmt.Errorf("解析自定义地址段文件 %s: %w", file, err)
+		return
+	}
+
+	entries := make([]entry, 0, len(records))
+	for i, rec := range records {
+		var e entry
+		if e, err = toEntry(rec); err != nil {
+			err = fmt.Errorf("%s 第 %d 条: %w", file, i+1, err)
+			return
+		}
+		entries = append(entries, e)
+	}
+
+	t = newTable(entries)
+	return
+}
+
+func toEntry(rec record) (e entry, err error) {
+	cidr := strings.TrimSpace(rec["cidr"])
+	if cidr == "" {
+		err = fmt.Errorf("缺少 cidr")
+		return
+	}
+
+	if strings.Contains(cidr, "/") {
+		if e.Prefix, err = netip.ParsePrefix(cidr); err != nil {
+			return
+		}
+	} else {
+		var addr netip.Addr
+		if addr, err = netip.ParseAddr(cidr); err != nil {
+			return
+		}
+		e.Prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	if e.Prefix.Addr().Is4In6() {
+		if e.Prefix.Bits() < 96 {
+			err = fmt.Errorf("IPv4 映射的 IPv6 网段前缀长度不能小于 96: %s", cidr)
+			return
+		}
+		e.Prefix = netip.PrefixFrom(e.Prefix.Addr().Unmap(), e.Prefix.Bits()-96)
+	}
+
+	kind := ip2region.Kind(strings.TrimSpace(rec["kind"]))
+	if kind != "" && kind != ip2region.KindPublic && !kind.Reserved() {
+		err = fmt.Errorf("无效的 kind: %s", kind)
+		return
+	}
+
+	e.Result = ip2region.Result{
+		Continent:   ip2region.NewName(rec["continent"], rec["continent_code"], 0),
+		Country:     ip2region.NewName(rec["country"], rec["country_code"], 0),
+		Subdivision: ip2region.NewName(rec["subdivision"], rec["subdivision_code"], 0),
+		City:        ip2region.NewName(rec["city"], "", 0),
+		ISP:         rec["isp"],
+		Kind:        kind,
+	}
+	return
+}
+
+// parseCSV 第一行为字段名
+func parseCSV(data []byte) (records []record, err error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	var header []string
+	for {
+		var row []string
+		if row, err = r.Read(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+
+		if header == nil {
+			for _, h := range row {
+				header = append(header, strings.ToLower(strings.TrimSpace(h)))
+			}
+			continue
+		}
+
+		rec := record{}
+		for i, v := range row {
+			if i < len(header) {
+				rec[header[i]] = strings.TrimSpace(v)
+			}
+		}
+		records = append(records, rec)
+	}
+}
+
+// parseYAML 内容为对象列表, 字段同 JSON
+func parseYAML(data []byte) (records []record, err error) {
+	err = yaml.Unmarshal(data, &records)
+	return
+}
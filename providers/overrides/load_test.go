@@ -0,0 +1,231 @@
+package overrides
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cnk3x/ip2region"
+)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []record
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"header only", "cidr,isp\n", nil, false},
+		{
+			"records",
+			"# 办公网\nCIDR, ISP ,Kind\n10.8.0.0/16, 办公网\n192.168.1.1,家庭,private\n",
+			[]record{{"cidr": "10.8.0.0/16", "isp": "办公网"}, {"cidr": "192.168.1.1", "isp": "家庭", "kind": "private"}},
+			false,
+		},
+		{"quoted", "cidr,city\n\"10.0.0.0/8\",\"a, b\"\n", []record{{"cidr": "10.0.0.0/8", "city": "a, b"}}, false},
+		{"extra fields ignored", "cidr\n10.0.0.0/8,x\n", []record{{"cidr": "10.0.0.0/8"}}, false},
+		{"unterminated quote", "cidr,isp\n10.0.0.0/8,\"x\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSV([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCSV() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSV() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []record
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{
+			"records",
+			"# 办公网\n- cidr: 10.8.0.0/16\n  isp: 办公网 # 注释\n- cidr: \"::ffff:192.168.0.0/112\"\n  country_code: 'CN'\n",
+			[]record{{"cidr": "10.8.0.0/16", "isp": "办公网"}, {"cidr": "::ffff:192.168.0.0/112", "country_code": "CN"}},
+			false,
+		},
+		{"flow style", `[{cidr: 10.0.0.0/8, city: "a: b"}]`, []record{{"cidr": "10.0.0.0/8", "city": "a: b"}}, false},
+		{"non-string scalar", "- cidr: 10.0.0.1\n  isp: 12345\n", []record{{"cidr": "10.0.0.1", "isp": "12345"}}, false},
+		{"not a list", "cidr: 10.0.0.0/8\n", nil, true},
+		{"bad indentation", "- cidr: 10.0.0.0/8\n isp: x\n  city: y\n", nil, true},
+		{"nested value", "- cidr: 10.0.0.0/8\n  isp: {name: x}\n", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseYAML() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAML() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToEntry(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		want    string
+		wantErr bool
+	}{
+		{"10.8.0.0/16", "10.8.0.0/16", false},
+		{" 10.8.1.2 ", "10.8.1.2/32", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		// IPv4 映射的 IPv6 网段转换为 IPv4 网段
+		{"::ffff:10.8.0.0/112", "10.8.0.0/16", false},
+		{"::ffff:10.8.0.1", "10.8.0.1/32", false},
+		{"::ffff:0.0.0.0/96", "0.0.0.0/0", false},
+		{"::ffff:0.0.0.0/80", "", true},
+		{"", "", true},
+		{"10.8.0.0/33", "", true},
+		{"10.8.0", "", true},
+		{"bogus/8", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			e, err := toEntry(record{"cidr": tt.cidr})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toEntry() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && e.Prefix.String() != tt.want {
+				t.Errorf("toEntry() prefix = %s, want %s", e.Prefix, tt.want)
+			}
+		})
+	}
+}
+
+func TestToEntryKind(t *testing.T) {
+	tests := []struct {
+		kind    string
+		want    ip2region.Kind
+		wantErr bool
+	}{
+		{"", "", false},
+		{"public", ip2region.KindPublic, false},
+		{" private ", ip2region.KindPrivate, false},
+		{"reserved", ip2region.KindReserved, false},
+		{"vpn", "", true},
+		{"Private", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			e, err := toEntry(record{"cidr": "10.8.0.0/16", "kind": tt.kind})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("toEntry() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && e.Result.Kind != tt.want {
+				t.Errorf("toEntry() kind = %s, want %s", e.Result.Kind, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	files := map[string]string{
+		"ok.csv":      "cidr,isp\n10.8.0.0/16,办公网\n",
+		"ok.json":     `[{"cidr":"10.8.0.0/16","isp":"办公网"}]`,
+		"ok.yaml":     "- cidr: 10.8.0.0/16\n  isp: 办公网\n",
+		"ok.yml":      "- cidr: 10.8.0.0/16\n  isp: 办公网\n",
+		"bad.json":    `{"cidr":"10.8.0.0/16"}`,
+		"bad.yaml":    "- cidr: ::ffff:0.0.0.0/64\n",
+		"missing.csv": "isp\n办公网\n",
+		"ok.txt":      "10.8.0.0/16",
+	}
+
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name := range files {
+		t.Run(name, func(t *testing.T) {
+			tbl, err := loadFile(filepath.Join(dir, name))
+			if wantErr := !strings.HasPrefix(name, "ok.") || name == "ok.txt"; wantErr {
+				if err == nil {
+					t.Error("loadFile() err = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r, ok := tbl.lookup(netip.MustParseAddr("10.8.3.4")); !ok || r.ISP != "办公网" {
+				t.Errorf("lookup() = %+v, %v", r, ok)
+			}
+		})
+	}
+}
+
+type nextProvider struct{}
+
+func (nextProvider) Search(_ context.Context, ip string, _ ...string) (*ip2region.Result, error) {
+	return &ip2region.Result{IP: ip, ISP: "next", Kind: ip2region.KindPublic}, nil
+}
+func (nextProvider) Update(context.Context) error   { return nil }
+func (nextProvider) Info() (*ip2region.Info, error) { return &ip2region.Info{}, nil }
+func (nextProvider) Close() error                   { return nil }
+
+func TestSearch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "overrides.csv")
+	data := "cidr,isp,kind\n10.8.0.0/16,办公网,\n10.8.1.0/24,机房,\n1.2.3.0/24,专线,\n::ffff:5.6.7.8,映射,public\n10.10.0.0/16,专网,public\n9.9.9.0/24,测试,documentation\n"
+	if err := os.WriteFile(file, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Open(nextProvider{}, file, &Options{Watch: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tests := []struct {
+		ip       string
+		isp      string
+		kind     ip2region.Kind
+		reserved bool
+	}{
+		{"10.8.3.4", "办公网", ip2region.KindPrivate, true},
+		{"10.8.1.4", "机房", ip2region.KindPrivate, true},
+		{"::ffff:10.8.1.4", "机房", ip2region.KindPrivate, true},
+		{"1.2.3.4", "专线", ip2region.KindPublic, false},
+		{"5.6.7.8", "映射", ip2region.KindPublic, false},
+		// kind 指定为 public 的私有地址不是特殊用途地址
+		{"10.10.0.1", "专网", ip2region.KindPublic, false},
+		{"9.9.9.9", "测试", ip2region.KindDocumentation, true},
+		{"10.9.0.1", "next", ip2region.KindPublic, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			r, err := p.Search(context.Background(), tt.ip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.ISP != tt.isp || r.Kind != tt.kind || r.Reserved != tt.reserved {
+				t.Errorf("Search() = %+v, want isp %s, kind %s, reserved %v", r, tt.isp, tt.kind, tt.reserved)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package overrides
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/cnk3x/ip2region/pkg/fileio"
+)
+
+// Options 自定义地址段选项
+type Options struct {
+	Watch   time.Duration     // 轮询自定义文件的间隔, 文件修改后自动重新加载, 默认 10s, 小于 0 表示不监听
+	Metrics ip2region.Metrics // 只记录命中自定义地址段的查询和无效地址, 其余由下层 Provider 记录
+}
+
+// Provider 在任意 Provider 之上叠加自定义地址段, 命中时直接返回自定义结果, 否则交给下层 Provider 查询
+type Provider struct {
+	next    ip2region.Provider
+	file    string
+	metrics ip2region.Metrics
+
+	mu    sync.RWMutex
+	table *table
+	stop  context.CancelFunc
+}
+
+// Open 从 file 加载自定义地址段(按扩展名识别 .csv, .json, .yaml/.yml)并包装 next
+func Open(next ip2region.Provider, file string, options *Options) (p *Provider, err error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if options.Watch == 0 {
+		options.Watch = 10 * time.Second
+	}
+
+	if options.Metrics == nil {
+		options.Metrics = ip2region.NopMetrics{}
+	}
+
+	var t *table
+	if t, err = loadFile(file); err != nil {
+		return
+	}
+
+	p = &Provider{next: next, file: file, table: t, metrics: options.Metrics}
+
+	if options.Watch > 0 {
+		var ctx context.Context
+		ctx, p.stop = context.WithCancel(context.Background())
+		fileio.Watch(ctx, file, options.Watch, p.reload)
+	}
+	return
+}
+
+func (p *Provider) reload() {
+	t, err := loadFile(p.file)
+	if err != nil {
+		slog.Warn("自定义地址段重新加载失败，继续使用旧数据", "path", p.file, "err", err)
+		return
+	}
+
+	p.mu.Lock()
+	p.table = t
+	p.mu.Unlock()
+	slog.Info("自定义地址段已重新加载", "path", p.file, "count", t.count)
+}
+
+// Search 命中自定义地址段时直接返回, 按 overrides 记录查询指标, 否则交给下层 Provider 查询并由其记录
+func (p *Provider) Search(ctx context.Context, ip string, langs ...string) (result *ip2region.Result, err error) {
+	start := time.Now()
+	var addr netip.Addr
+	if addr, err = ip2region.ParseAddr(ip); err != nil {
+		// 无效的地址不会交给下层 Provider, 在这里记录
+		p.metrics.ObserveLookup("overrides", time.Since(start), err)
+		return
+	}
+
+	p.mu.RLock()
+	r, ok := p.table.lookup(addr)
+	p.mu.RUnlock()
+
+	if !ok {
+		return p.next.Search(ctx, ip, langs...)
+	}
+
+	r.IP = addr.String()
+	if r.Kind == "" {
+		r.Kind = ip2region.Classify(addr)
+	}
+	r.Reserved = r.Kind.Reserved()
+	p.metrics.ObserveLookup("overrides", time.Since(start), nil)
+	return &r, nil
+}
+
+func (p *Provider) Update(ctx context.Context) error {
+	return p.next.Update(ctx)
+}
+
+// Rollback 回滚下层 Provider 的地址库
+func (p *Provider) Rollback(ctx context.Context) error {
+	if r, ok := p.next.(interface {
+		Rollback(ctx context.Context) error
+	}); ok {
+		return r.Rollback(ctx)
+	}
+	return errors.New("数据库不支持回滚")
+}
+
+func (p *Provider) Info() (info *ip2region.Info, err error) {
+	if info, err = p.next.Info(); err != nil {
+		return
+	}
+
+	p.mu.RLock()
+	count := p.table.count
+	p.mu.RUnlock()
+
+	if info.Meta == nil {
+		info.Meta = map[string]any{}
+	}
+	info.Meta["overrides"] = fmt.Sprintf("%s (%d)", p.file, count)
+	return
+}
+
+func (p *Provider) Close() error {
+	if p.stop != nil {
+		p.stop()
+	}
+	return p.next.Close()
+}
+
+// table 按前缀长度分组的地址段, 用于最长前缀匹配
+type table struct {
+	prefixes map[netip.Prefix]ip2region.Result
+	bits4    []int // IPv4 前缀长度, 从长到短
+	bits6    []int // IPv6 前缀长度, 从长到短
+	count    int
+}
+
+func newTable(entries []entry) *table {
+	t := &table{prefixes: make(map[netip.Prefix]ip2region.Result, len(entries))}
+
+	seen := map[int]bool{}
+	for _, e := range entries {
+		p := e.Prefix.Masked()
+		t.prefixes[p] = e.Result
+
+		key := p.Bits()
+		if p.Addr().Is6() {
+			key += 1000
+		}
+		if !seen[key] {
+			seen[key] = true
+			if p.Addr().Is4() {
+				t.bits4 = append(t.bits4, p.Bits())
+			} else {
+				t.bits6 = append(t.bits6, p.Bits())
+			}
+		}
+	}
+	t.count = len(t.prefixes)
+
+	sort.Sort(sort.Reverse(sort.IntSlice(t.bits4)))
+	sort.Sort(sort.Reverse(sort.IntSlice(t.bits6)))
+	return t
+}
+
+func (t *table) lookup(addr netip.Addr) (r ip2region.Result, ok bool) {
+	bits := t.bits6
+	if addr.Is4() {
+		bits = t.bits4
+	}
+
+	for _, b := range bits {
+		p, err := addr.Prefix(b)
+		if err != nil {
+			continue
+		}
+		if r, ok = t.prefixes[p]; ok {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package remote
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+)
+
+// lru 固定容量的查询结果缓存, 超过容量时淘汰最久未使用的结果
+type lru struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	ll    *list.List // 最近使用的在前
+	items map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	result  ip2region.Result
+	expires time.Time // ttl 为 0 时为零值
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{size: size, ttl: ttl, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+func (c *lru) get(key string) (r ip2region.Result, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el := c.items[key]
+	if el == nil {
+		return
+	}
+
+	e := el.Value.(*lruEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return
+	}
+
+	c.ll.MoveToFront(el)
+	return e.result, true
+}
+
+func (c *lru) put(key string, r ip2region.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	if el := c.items[key]; el != nil {
+		el.Value = &lruEntry{key: key, result: r, expires: expires}
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, result: r, expires: expires})
+	for c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lru) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	clear(c.items)
+}
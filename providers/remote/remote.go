@@ -0,0 +1,372 @@
+// Package remote 通过 ip2region web 服务的 HTTP 接口查询, 实现 ip2region.Provider
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/cnk3x/ip2region/pkg/httpio"
+)
+
+// 指标中的 provider 名称
+const providerName = "remote"
+
+// Options 远程查询选项
+type Options struct {
+	// APIKey 通过 X-API-Key 头发送的 API Key, 服务端启用了 --api-keys 时需要
+	APIKey string
+	// UpdateToken 调用服务端 /update 接口的 Bearer 令牌, 为空时 Update 返回 ip2region.ErrReadOnly
+	UpdateToken string
+	// Timeout 单次请求的超时时间, 默认 5s
+	Timeout time.Duration
+	// Retries 请求失败(网络错误, 429, 502, 503, 504)后的重试次数, 默认 2, 小于 0 表示不重试
+	Retries int
+	// MaxIdleConns 保持的空闲连接数, 默认 16
+	MaxIdleConns int
+	// CacheSize 本地缓存的查询结果数量, 0 表示不缓存
+	CacheSize int
+	// CacheTTL 缓存的有效期, 0 表示一直有效直到被淘汰, 本地调用 Update 成功后清空缓存
+	CacheTTL time.Duration
+	// UpdatePoll 等待服务端更新完成时查询任务状态的间隔, 默认 2s
+	UpdatePoll time.Duration
+	// Transport 自定义的 http.RoundTripper, 设置后忽略 MaxIdleConns
+	Transport http.RoundTripper
+	// Metrics 监控指标, 默认不记录
+	Metrics ip2region.Metrics
+}
+
+// Provider 通过 HTTP 接口查询远程的 ip2region web 服务
+type Provider struct {
+	base      string
+	options   Options
+	transport http.RoundTripper
+	cache     *lru
+}
+
+// Open 创建查询 baseURL(如 http://ip2region.internal:3824) 的 Provider, 不会发起请求
+func Open(baseURL string, options *Options) (p *Provider, err error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的服务地址: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("无效的服务地址: %s", baseURL)
+	}
+
+	p = &Provider{base: strings.TrimSuffix(u.String(), "/"), options: *options}
+
+	o := &p.options
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.Retries == 0 {
+		o.Retries = 2
+	}
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = 16
+	}
+	if o.UpdatePoll <= 0 {
+		o.UpdatePoll = 2 * time.Second
+	}
+	if o.Metrics == nil {
+		o.Metrics = ip2region.NopMetrics{}
+	}
+
+	if p.transport = o.Transport; p.transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.MaxIdleConns = o.MaxIdleConns
+		t.MaxIdleConnsPerHost = o.MaxIdleConns
+		p.transport = t
+	}
+
+	if o.CacheSize > 0 {
+		p.cache = newLRU(o.CacheSize, o.CacheTTL)
+	}
+	return
+}
+
+// lookupResponse /v1/lookup 接口的响应, 见 cmd/ip2region/openapi.json 中的 Lookup
+type lookupResponse struct {
+	IP          string `json:"ip"`
+	Kind        string `json:"kind"`
+	Reserved    bool   `json:"reserved"`
+	Continent   *place `json:"continent"`
+	Country     *place `json:"country"`
+	Subdivision *place `json:"subdivision"`
+	City        *place `json:"city"`
+	ISP         string `json:"isp"`
+}
+
+type place struct {
+	Name      string `json:"name"`
+	Code      string `json:"code"`
+	GeoNameID uint   `json:"geoname_id"`
+}
+
+func (pl *place) name() ip2region.Name {
+	if pl == nil {
+		return ip2region.Name{}
+	}
+	return ip2region.NewName(pl.Name, pl.Code, pl.GeoNameID)
+}
+
+func (p *Provider) Search(ctx context.Context, ip string, langs ...string) (out *ip2region.Result, err error) {
+	start := time.Now()
+	defer func() { p.options.Metrics.ObserveLookup(providerName, time.Since(start), err) }()
+
+	key := ip + "|" + strings.Join(langs, ",")
+	if p.cache != nil {
+		r, ok := p.cache.get(key)
+		p.options.Metrics.ObserveCache(providerName, ok)
+		if ok {
+			return &r, nil
+		}
+	}
+
+	query := url.Values{}
+	if len(langs) > 0 {
+		query.Set("lang", strings.Join(langs, ","))
+	}
+
+	var resp lookupResponse
+	if err = p.do(ctx, http.MethodGet, "/v1/lookup/"+url.PathEscape(ip), query, "", &resp); err != nil {
+		return
+	}
+
+	out = &ip2region.Result{
+		IP:          resp.IP,
+		Continent:   resp.Continent.name(),
+		Country:     resp.Country.name(),
+		Subdivision: resp.Subdivision.name(),
+		City:        resp.City.name(),
+		ISP:         resp.ISP,
+		Reserved:    resp.Reserved,
+		Kind:        ip2region.Kind(resp.Kind),
+	}
+
+	if p.cache != nil {
+		p.cache.put(key, *out)
+	}
+	return
+}
+
+func (p *Provider) Info() (info *ip2region.Info, err error) {
+	info = &ip2region.Info{}
+	if err = p.do(context.Background(), http.MethodGet, "/info", nil, "", info); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// updateJob 服务端的更新任务
+type updateJob struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // running, succeeded, failed
+	Err    string `json:"err"`
+}
+
+// Update 让服务端更新地址库并等待完成, 服务端已有更新任务时等待该任务完成
+func (p *Provider) Update(ctx context.Context) (err error) {
+	if p.options.UpdateToken == "" {
+		return fmt.Errorf("%w: 未设置 UpdateToken", ip2region.ErrReadOnly)
+	}
+
+	start := time.Now()
+	defer func() { p.options.Metrics.ObserveUpdate(providerName, time.Since(start), err) }()
+
+	var job updateJob
+	if err = p.send(ctx, http.MethodPost, "/update", nil, p.options.UpdateToken, &job); err != nil {
+		var se *Error
+		switch {
+		case !errors.As(err, &se):
+			return
+		case se.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("%w: 服务端未启用更新接口: %w", ip2region.ErrReadOnly, err)
+		case se.StatusCode != http.StatusConflict || se.job == nil:
+			return
+		}
+		// 已有更新任务在运行, 等待该任务完成
+		job, err = *se.job, nil
+	}
+
+	t := time.NewTicker(p.options.UpdatePoll)
+	defer t.Stop()
+
+	for job.Status == "running" {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-t.C:
+		}
+
+		if err = p.do(ctx, http.MethodGet, "/update/"+url.PathEscape(job.ID), nil, p.options.UpdateToken, &job); err != nil {
+			return
+		}
+	}
+
+	if job.Status != "succeeded" {
+		return fmt.Errorf("服务端更新失败: %s", job.Err)
+	}
+
+	if p.cache != nil {
+		p.cache.clear()
+	}
+	return
+}
+
+// Close 关闭空闲连接
+func (p *Provider) Close() error {
+	if t, ok := p.transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+// do 发送请求, 可重试的错误按指数退避重试, 无法连接服务端时返回的错误包装为 ip2region.ErrClosed
+func (p *Provider) do(ctx context.Context, method, path string, query url.Values, token string, out any) (err error) {
+	defer func() {
+		var se *Error
+		if err != nil && ctx.Err() == nil && !errors.As(err, &se) {
+			err = fmt.Errorf("%w: %w", ip2region.ErrClosed, err)
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		if err = p.send(ctx, method, path, query, token, out); err == nil || attempt >= p.options.Retries {
+			return
+		}
+
+		wait, ok := retryAfter(err, attempt)
+		if !ok || ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// send 发送一次请求, 成功时将 JSON 响应解析到 out
+func (p *Provider) send(ctx context.Context, method, path string, query url.Values, token string, out any) error {
+	u := p.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	opts := []httpio.RequestOption{httpio.Method(method), httpio.HeaderSet("Accept", "application/json")}
+	if p.options.APIKey != "" {
+		opts = append(opts, httpio.HeaderSet("X-API-Key", p.options.APIKey))
+	}
+	if token != "" {
+		opts = append(opts, httpio.HeaderSet("Authorization", "Bearer "+token))
+	}
+
+	return httpio.New(u, opts...).
+		Client(httpio.Timeout(p.options.Timeout), httpio.Transport(p.transport)).
+		Do(ctx, func(resp *http.Response) error {
+			if resp.StatusCode >= http.StatusBadRequest {
+				return newError(resp)
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return err
+			}
+			// 读完响应才能复用连接
+			_, err := io.Copy(io.Discard, resp.Body)
+			return err
+		})
+}
+
+// retryAfter 返回重试前等待的时间, 不可重试的错误返回 false
+func retryAfter(err error, attempt int) (wait time.Duration, ok bool) {
+	// 100ms, 200ms, 400ms ... 最多 2s, 加上随机抖动
+	wait = min(100*time.Millisecond<<attempt, 2*time.Second)
+	wait += rand.N(wait / 2)
+
+	var se *Error
+	if errors.As(err, &se) {
+		switch se.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			if se.RetryAfter > wait {
+				wait = se.RetryAfter
+			}
+			// 服务端要求等待的时间过长时直接返回错误
+			return wait, wait <= 10*time.Second
+		}
+		return 0, false
+	}
+
+	var ne net.Error
+	return wait, errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// Error 服务端返回的错误, 可以用 errors.Is 判断错误类型, 如 ip2region.ErrNotFound
+type Error struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // 响应的 Retry-After 头
+
+	job *updateJob // 已有更新任务在运行时(409)返回的任务
+}
+
+func newError(resp *http.Response) *Error {
+	e := &Error{StatusCode: resp.StatusCode}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+
+	// /v1 接口为 {"status":..,"error":".."}, 其他接口为 {"err":".."}
+	var body struct {
+		Error string     `json:"error"`
+		Err   string     `json:"err"`
+		Job   *updateJob `json:"job"`
+	}
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(b, &body) == nil {
+		e.Message, e.job = body.Error+body.Err, body.Job
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(b))
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("服务端返回 %d", e.StatusCode)
+	}
+	return fmt.Sprintf("服务端返回 %d: %s", e.StatusCode, e.Message)
+}
+
+// Unwrap 按状态码返回对应的错误, 与服务端 errStatus 的对应关系相反
+func (e *Error) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return ip2region.ErrInvalidIP
+	case http.StatusNotFound:
+		return ip2region.ErrNotFound
+	case http.StatusServiceUnavailable:
+		return ip2region.ErrClosed
+	case http.StatusBadGateway:
+		return ip2region.ErrDownload
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,354 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+)
+
+const lookupJSON = `{"ip":"1.2.3.4","kind":"public","country":{"name":"中国","code":"CN","geoname_id":1814991},"city":{"name":"深圳市"},"isp":"电信"}`
+
+// reply 测试服务端的一次响应
+type reply struct {
+	status     int
+	retryAfter string
+	body       string
+}
+
+// newServer 按顺序返回 replies, 用完后重复最后一个, 返回请求次数的计数器
+func newServer(t *testing.T, replies ...reply) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(n.Add(1)) - 1
+		rp := replies[min(i, len(replies)-1)]
+		if rp.retryAfter != "" {
+			w.Header().Set("Retry-After", rp.retryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rp.status)
+		fmt.Fprint(w, rp.body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func TestSearchRetry(t *testing.T) {
+	ok := reply{http.StatusOK, "", lookupJSON}
+	unavailable := reply{http.StatusServiceUnavailable, "", `{"status":503,"error":"database missing"}`}
+
+	tests := []struct {
+		name     string
+		retries  int
+		replies  []reply
+		attempts int32
+		wantErr  error
+		minWait  time.Duration
+	}{
+		{"ok", 2, []reply{ok}, 1, nil, 0},
+		{"503 then ok", 2, []reply{unavailable, ok}, 2, nil, 0},
+		{"502 then 504 then ok", 2, []reply{{http.StatusBadGateway, "", ""}, {http.StatusGatewayTimeout, "", ""}, ok}, 3, nil, 0},
+		{"503 retries exhausted", 2, []reply{unavailable}, 3, ip2region.ErrClosed, 0},
+		{"no retries", -1, []reply{unavailable, ok}, 1, ip2region.ErrClosed, 0},
+		{"429 with retry-after", 2, []reply{{http.StatusTooManyRequests, "1", `{"status":429,"error":"请求过于频繁"}`}, ok}, 2, nil, time.Second},
+		{"429 retry-after too long", 2, []reply{{http.StatusTooManyRequests, "60", ""}, ok}, 1, nil, 0},
+		{"400 not retried", 2, []reply{{http.StatusBadRequest, "", `{"status":400,"error":"invalid ip"}`}, ok}, 1, ip2region.ErrInvalidIP, 0},
+		{"404 not retried", 2, []reply{{http.StatusNotFound, "", `{"status":404,"error":"not found"}`}, ok}, 1, ip2region.ErrNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, n := newServer(t, tt.replies...)
+			p, err := Open(srv.URL, &Options{Retries: tt.retries})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			r, err := p.Search(context.Background(), "1.2.3.4")
+			if got := n.Load(); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minWait {
+				t.Errorf("elapsed = %s, want at least %s", elapsed, tt.minWait)
+			}
+
+			var se *Error
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.attempts == 1 && tt.replies[0].status != http.StatusOK:
+				// 不重试时返回服务端的错误
+				if !errors.As(err, &se) || se.StatusCode != tt.replies[0].status {
+					t.Errorf("err = %v, want status %d", err, tt.replies[0].status)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case r.Country.Code != "CN" || r.City.Name != "深圳市" || r.ISP != "电信" || r.Kind != ip2region.KindPublic:
+				t.Errorf("Search() = %+v", r)
+			}
+		})
+	}
+}
+
+func TestSearchUnreachable(t *testing.T) {
+	srv, _ := newServer(t, reply{http.StatusOK, "", lookupJSON})
+	srv.Close()
+
+	p, err := Open(srv.URL, &Options{Retries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Search(context.Background(), "1.2.3.4"); !errors.Is(err, ip2region.ErrClosed) {
+		t.Errorf("err = %v, want %v", err, ip2region.ErrClosed)
+	}
+}
+
+func TestErrorUnwrap(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ip2region.ErrInvalidIP},
+		{http.StatusNotFound, ip2region.ErrNotFound},
+		{http.StatusServiceUnavailable, ip2region.ErrClosed},
+		{http.StatusBadGateway, ip2region.ErrDownload},
+		{http.StatusUnauthorized, nil},
+		{http.StatusTooManyRequests, nil},
+		{http.StatusInternalServerError, nil},
+	}
+
+	for _, tt := range tests {
+		err := &Error{StatusCode: tt.status}
+		if got := err.Unwrap(); got != tt.want {
+			t.Errorf("Unwrap(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+		if tt.want != nil && !errors.Is(fmt.Errorf("wrapped: %w", err), tt.want) {
+			t.Errorf("errors.Is(%d, %v) = false", tt.status, tt.want)
+		}
+	}
+}
+
+func TestNewError(t *testing.T) {
+	tests := []struct {
+		name string
+		rp   reply
+		msg  string
+		wait time.Duration
+	}{
+		{"v1", reply{http.StatusNotFound, "", `{"status":404,"error":"not found"}`}, "not found", 0},
+		{"legacy", reply{http.StatusServiceUnavailable, "3", `{"err":"closed"}`}, "closed", 3 * time.Second},
+		{"plain text", reply{http.StatusBadGateway, "", "bad gateway\n"}, "bad gateway", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, tt.rp)
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			e := newError(resp)
+			if e.StatusCode != tt.rp.status || e.Message != tt.msg || e.RetryAfter != tt.wait {
+				t.Errorf("newError() = %+v", e)
+			}
+		})
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	srv, n := newServer(t, reply{http.StatusOK, "", lookupJSON})
+	p, err := Open(srv.URL, &Options{CacheSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, q := range []struct {
+		ip    string
+		langs []string
+	}{{"1.2.3.4", nil}, {"1.2.3.4", nil}, {"1.2.3.4", []string{"en"}}, {"1.2.3.4", nil}} {
+		if _, err = p.Search(ctx, q.ip, q.langs...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 不同的语言分别缓存
+	if got := n.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestLRU(t *testing.T) {
+	result := func(ip string) ip2region.Result { return ip2region.Result{IP: ip} }
+
+	t.Run("eviction", func(t *testing.T) {
+		c := newLRU(2, 0)
+		c.put("a", result("a"))
+		c.put("b", result("b"))
+		c.get("a") // a 变为最近使用
+		c.put("c", result("c"))
+
+		for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+			if _, ok := c.get(key); ok != want {
+				t.Errorf("get(%s) ok = %v, want %v", key, ok, want)
+			}
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		c := newLRU(2, 0)
+		c.put("a", result("a"))
+		c.put("b", result("b"))
+		c.put("a", result("a2"))
+		c.put("c", result("c"))
+
+		if r, ok := c.get("a"); !ok || r.IP != "a2" {
+			t.Errorf("get(a) = %v, %v, want a2", r.IP, ok)
+		}
+		if _, ok := c.get("b"); ok {
+			t.Error("b should be evicted")
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		c := newLRU(2, 20*time.Millisecond)
+		c.put("a", result("a"))
+		if _, ok := c.get("a"); !ok {
+			t.Fatal("get(a) before ttl should hit")
+		}
+
+		time.Sleep(30 * time.Millisecond)
+		if _, ok := c.get("a"); ok {
+			t.Error("get(a) after ttl should miss")
+		}
+		if c.ll.Len() != 0 || len(c.items) != 0 {
+			t.Errorf("expired entry not removed: %d, %d", c.ll.Len(), len(c.items))
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		c := newLRU(2, 0)
+		c.put("a", result("a"))
+		c.clear()
+		if _, ok := c.get("a"); ok {
+			t.Error("get(a) after clear should miss")
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		post    reply
+		polls   []string // GET /update/{id} 依次返回的任务状态
+		wantErr error
+		errMsg  bool // 返回了非哨兵错误
+	}{
+		{"accepted", reply{http.StatusAccepted, "", `{"id":"j1","status":"running"}`}, []string{"running", "succeeded"}, nil, false},
+		{"already running", reply{http.StatusConflict, "", `{"err":"更新任务已在运行","job":{"id":"j1","status":"running"}}`}, []string{"running", "running", "succeeded"}, nil, false},
+		{"already running fails", reply{http.StatusConflict, "", `{"err":"更新任务已在运行","job":{"id":"j1","status":"running"}}`}, []string{"failed"}, nil, true},
+		{"conflict without job", reply{http.StatusConflict, "", `{"err":"conflict"}`}, nil, nil, true},
+		{"disabled", reply{http.StatusForbidden, "", `{"err":"forbidden"}`}, nil, ip2region.ErrReadOnly, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls atomic.Int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /update", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(tt.post.status)
+				fmt.Fprint(w, tt.post.body)
+			})
+			mux.HandleFunc("GET /update/j1", func(w http.ResponseWriter, r *http.Request) {
+				i := int(polls.Add(1)) - 1
+				fmt.Fprintf(w, `{"id":"j1","status":%q,"err":"download failed"}`, tt.polls[min(i, len(tt.polls)-1)])
+			})
+			mux.HandleFunc("GET /v1/lookup/{ip}", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, lookupJSON)
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			p, err := Open(srv.URL, &Options{UpdateToken: "token", UpdatePoll: 5 * time.Millisecond, CacheSize: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = p.Search(context.Background(), "1.2.3.4"); err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.Update(context.Background())
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Update() err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.errMsg:
+				if err == nil {
+					t.Error("Update() should fail")
+				}
+			case err != nil:
+				t.Fatalf("Update() err = %v", err)
+			default:
+				if got := int(polls.Load()); got != len(tt.polls) {
+					t.Errorf("polls = %d, want %d", got, len(tt.polls))
+				}
+				// 更新成功后清空缓存
+				if len(p.cache.items) != 0 {
+					t.Errorf("cache not cleared: %d items", len(p.cache.items))
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateReadOnly(t *testing.T) {
+	p, err := Open("http://127.0.0.1:1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Update(context.Background()); !errors.Is(err, ip2region.ErrReadOnly) {
+		t.Errorf("Update() err = %v, want %v", err, ip2region.ErrReadOnly)
+	}
+}
+
+func TestUpdateCanceled(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /update", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `{"err":"更新任务已在运行","job":{"id":"j1","status":"running"}}`)
+	})
+	mux.HandleFunc("GET /update/j1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"j1","status":"running"}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	p, err := Open(srv.URL, &Options{UpdateToken: "token", UpdatePoll: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = p.Update(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Update() err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
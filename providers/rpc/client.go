@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 指标中的 provider 名称
+const providerName = "grpc"
+
+// Options 客户端选项
+type Options struct {
+	// DialOptions 连接选项, 如 grpc.WithTransportCredentials, 默认使用不加密的连接
+	DialOptions []grpc.DialOption
+	// APIKey 通过 x-api-key 元数据发送的 API Key, 服务端启用了 --api-keys 时需要
+	APIKey string
+	// Timeout 单次查询的超时时间, ctx 已有截止时间时不使用, 默认 5s
+	Timeout time.Duration
+	// Metrics 监控指标, 默认不记录
+	Metrics ip2region.Metrics
+}
+
+// Client 通过 gRPC 服务查询的 Provider, 可以替换本地的 Provider 使用。
+// 地址库由服务端更新, Update 返回 ip2region.ErrReadOnly。
+type Client struct {
+	conn    *grpc.ClientConn
+	client  LookupClient
+	timeout time.Duration
+	metrics ip2region.Metrics
+}
+
+// Dial 创建连接到 target(如 localhost:3825) 的客户端, 连接在第一次调用时建立, 断开后自动重连
+func Dial(target string, options *Options) (c *Client, err error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	c = &Client{timeout: options.Timeout, metrics: options.Metrics}
+	if c.timeout <= 0 {
+		c.timeout = 5 * time.Second
+	}
+	if c.metrics == nil {
+		c.metrics = ip2region.NopMetrics{}
+	}
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if options.APIKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(apiKeyCredentials(options.APIKey)))
+	}
+	opts = append(opts, options.DialOptions...)
+	if c.conn, err = grpc.NewClient(target, opts...); err != nil {
+		return nil, err
+	}
+	c.client = NewLookupClient(c.conn)
+	return
+}
+
+func (c *Client) Search(ctx context.Context, ip string, langs ...string) (out *ip2region.Result, err error) {
+	start := time.Now()
+	defer func() { c.metrics.ObserveLookup(providerName, time.Since(start), err) }()
+
+	ctx, cancel := c.context(ctx)
+	defer cancel()
+
+	var trailer metadata.MD
+	r, err := c.client.Lookup(ctx, &LookupRequest{Ip: ip, Langs: langs}, grpc.Trailer(&trailer))
+	if err != nil {
+		return nil, convertError(err, trailer)
+	}
+	return r.ToResult(), nil
+}
+
+// BatchResult 批量查询中一个IP的结果
+type BatchResult struct {
+	IP     string
+	Result *ip2region.Result
+	Err    error
+}
+
+// BatchSearch 通过一个流查询多个IP, 结果与 ips 的顺序相同, 单个IP的错误在 BatchResult.Err 中,
+// 流中断时返回已收到的结果和错误
+func (c *Client) BatchSearch(ctx context.Context, ips []string, langs ...string) (results []BatchResult, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.BatchLookup(ctx)
+	if err != nil {
+		return nil, convertError(err, nil)
+	}
+
+	go func() {
+		for _, ip := range ips {
+			// 发送失败时流已结束, 错误由 Recv 返回
+			if stream.Send(&LookupRequest{Ip: ip, Langs: langs}) != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	results = make([]BatchResult, 0, len(ips))
+	for range ips {
+		out, err := stream.Recv()
+		if err != nil {
+			return results, convertError(err, stream.Trailer())
+		}
+
+		item := BatchResult{IP: out.GetIp(), Err: remoteError(out.GetErrorType(), out.GetError())}
+		if out.GetResult() != nil {
+			item.Result = out.GetResult().ToResult()
+		}
+		results = append(results, item)
+	}
+	return
+}
+
+func (c *Client) Info() (*ip2region.Info, error) {
+	ctx, cancel := c.context(context.Background())
+	defer cancel()
+
+	var trailer metadata.MD
+	i, err := c.client.Info(ctx, &InfoRequest{}, grpc.Trailer(&trailer))
+	if err != nil {
+		return nil, convertError(err, trailer)
+	}
+	return i.ToInfo(), nil
+}
+
+func (c *Client) Update(context.Context) error {
+	return fmt.Errorf("%w: 地址库由 gRPC 服务端更新", ip2region.ErrReadOnly)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// apiKeyCredentials 在每个请求的元数据中发送 API Key, 是否加密由连接决定
+type apiKeyCredentials string
+
+func (k apiKeyCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"x-api-key": string(k)}, nil
+}
+
+func (apiKeyCredentials) RequireTransportSecurity() bool { return false }
+
+func (c *Client) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// convertError 按 trailer 中的错误类型还原服务端的错误, 服务不可用时包装为 ip2region.ErrClosed
+func convertError(err error, trailer metadata.MD) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	if types := trailer.Get(errorTypeKey); len(types) > 0 {
+		return remoteError(types[0], st.Message())
+	}
+
+	if st.Code() == codes.Unavailable {
+		return fmt.Errorf("%w: %w", ip2region.ErrClosed, err)
+	}
+	return err
+}
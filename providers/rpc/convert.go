@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func newResult(r *ip2region.Result) *Result {
+	return &Result{
+		Ip:          r.IP,
+		Continent:   newName(r.Continent),
+		Country:     newName(r.Country),
+		Subdivision: newName(r.Subdivision),
+		City:        newName(r.City),
+		Isp:         r.ISP,
+		Reserved:    r.Reserved,
+		Kind:        string(r.Kind),
+	}
+}
+
+// ToResult 转换为 ip2region.Result
+func (x *Result) ToResult() *ip2region.Result {
+	return &ip2region.Result{
+		IP:          x.GetIp(),
+		Continent:   x.GetContinent().toName(),
+		Country:     x.GetCountry().toName(),
+		Subdivision: x.GetSubdivision().toName(),
+		City:        x.GetCity().toName(),
+		ISP:         x.GetIsp(),
+		Reserved:    x.GetReserved(),
+		Kind:        ip2region.Kind(x.GetKind()),
+	}
+}
+
+// newName 空的名称返回 nil, 不编码该字段
+func newName(n ip2region.Name) *Name {
+	if n == (ip2region.Name{}) {
+		return nil
+	}
+	return &Name{Name: n.Name, Code: n.Code, Id: uint64(n.ID)}
+}
+
+func (x *Name) toName() ip2region.Name {
+	return ip2region.NewName(x.GetName(), x.GetCode(), uint(x.GetId()))
+}
+
+// newInfoResponse Meta 的值在传输时转换为字符串
+func newInfoResponse(i *ip2region.Info) *InfoResponse {
+	out := &InfoResponse{
+		Type:      i.Type,
+		File:      i.File,
+		Size:      i.Size,
+		ModTime:   newTimestamp(i.ModTime),
+		BuildTime: newTimestamp(i.BuildTime),
+		Stale:     i.Stale,
+	}
+
+	if len(i.Meta) > 0 {
+		out.Meta = make(map[string]string, len(i.Meta))
+		for k, v := range i.Meta {
+			out.Meta[k] = fmt.Sprint(v)
+		}
+	}
+	return out
+}
+
+// ToInfo 转换为 ip2region.Info
+func (x *InfoResponse) ToInfo() *ip2region.Info {
+	info := &ip2region.Info{
+		Type:      x.GetType(),
+		File:      x.GetFile(),
+		Size:      x.GetSize(),
+		ModTime:   toTime(x.GetModTime()),
+		BuildTime: toTime(x.GetBuildTime()),
+		Stale:     x.GetStale(),
+	}
+
+	if len(x.GetMeta()) > 0 {
+		info.Meta = make(map[string]any, len(x.GetMeta()))
+		for k, v := range x.GetMeta() {
+			info.Meta[k] = v
+		}
+	}
+	return info
+}
+
+// newTimestamp 零值时间返回 nil, 不编码该字段
+func newTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func toTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
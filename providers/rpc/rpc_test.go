@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cnk3x/ip2region"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeProvider struct{}
+
+func (fakeProvider) Search(_ context.Context, ip string, langs ...string) (*ip2region.Result, error) {
+	switch ip {
+	case "1.2.3.4":
+		name := "中国"
+		if len(langs) > 0 && langs[0] == "en" {
+			name = "China"
+		}
+		return &ip2region.Result{
+			IP:          ip,
+			Country:     ip2region.NewName(name, "CN", 1814991),
+			Subdivision: ip2region.NewName("", "CN-GD", 0),
+			ISP:         "电信",
+			Kind:        ip2region.KindPublic,
+		}, nil
+	case "10.0.0.1":
+		return &ip2region.Result{IP: ip, Reserved: true, Kind: ip2region.KindPrivate}, nil
+	case "9.9.9.9":
+		return nil, ip2region.ErrNotFound
+	default:
+		return nil, ip2region.ErrInvalidIP
+	}
+}
+
+func (fakeProvider) Update(context.Context) error { return nil }
+
+func (fakeProvider) Info() (*ip2region.Info, error) {
+	return &ip2region.Info{
+		Type:      "xdb",
+		Size:      1024,
+		BuildTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Meta:      map[string]any{"segments": 3},
+	}, nil
+}
+
+func (fakeProvider) Close() error { return nil }
+
+// newTestClient 启动同时注册了查询服务和标准健康检查服务的 grpc.Server
+func newTestClient(t *testing.T) (*Client, *grpc.ClientConn) {
+	t.Helper()
+
+	ln := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s, fakeProvider{})
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+
+	dial := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) })
+	c, err := Dial("passthrough:///bufnet", &Options{DialOptions: []grpc.DialOption{dial}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", dial, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return c, conn
+}
+
+func TestSearch(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	r, err := c.Search(context.Background(), "1.2.3.4", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ip2region.Result{
+		IP:          "1.2.3.4",
+		Country:     ip2region.NewName("China", "CN", 1814991),
+		Subdivision: ip2region.NewName("", "CN-GD", 0),
+		ISP:         "电信",
+		Kind:        ip2region.KindPublic,
+	}
+	if *r != want {
+		t.Errorf("Search = %+v, want %+v", *r, want)
+	}
+
+	for ip, target := range map[string]error{"bogus": ip2region.ErrInvalidIP, "9.9.9.9": ip2region.ErrNotFound} {
+		if _, err := c.Search(context.Background(), ip); !errors.Is(err, target) {
+			t.Errorf("Search(%s) err = %v, want %v", ip, err, target)
+		}
+	}
+}
+
+func TestBatchSearch(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	results, err := c.BatchSearch(context.Background(), []string{"1.2.3.4", "bogus", "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if r := results[0]; r.Err != nil || r.Result.Country.Code != "CN" {
+		t.Errorf("results[0] = %+v", r)
+	}
+	if r := results[1]; r.IP != "bogus" || r.Result != nil || !errors.Is(r.Err, ip2region.ErrInvalidIP) {
+		t.Errorf("results[1] = %+v", r)
+	}
+	if r := results[2]; r.Err != nil || !r.Result.Reserved || r.Result.Kind != ip2region.KindPrivate {
+		t.Errorf("results[2] = %+v", r)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	c, _ := newTestClient(t)
+
+	info, err := c.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != "xdb" || info.Size != 1024 || !info.BuildTime.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) || !info.ModTime.IsZero() {
+		t.Errorf("Info = %+v", info)
+	}
+	if info.Meta["segments"] != "3" {
+		t.Errorf("Info.Meta = %v", info.Meta)
+	}
+}
+
+// 查询服务使用默认的 protobuf 编解码, 同一个 grpc.Server 上的其他服务不受影响
+func TestOtherServices(t *testing.T) {
+	_, conn := newTestClient(t)
+
+	resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health status = %v", resp.GetStatus())
+	}
+}
@@ -0,0 +1,128 @@
+// Package rpc 通过 gRPC 提供查询服务, 以及基于该服务实现 ip2region.Provider 的客户端。
+// 服务定义见 ip2region.proto, ip2region.pb.go 和 ip2region_grpc.pb.go 由其生成。
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative ip2region.proto
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/cnk3x/ip2region"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 响应 trailer 中的错误类型, 同 ip2region.ErrorType
+const errorTypeKey = "ip2region-error"
+
+// Register 在 s 上注册查询服务, 查询交给 p
+func Register(s grpc.ServiceRegistrar, p ip2region.Provider) {
+	RegisterLookupServer(s, &server{p: p})
+}
+
+type server struct {
+	UnimplementedLookupServer
+	p ip2region.Provider
+}
+
+func (s *server) Lookup(ctx context.Context, in *LookupRequest) (*Result, error) {
+	r, err := s.p.Search(ctx, in.GetIp(), in.GetLangs()...)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	out := newResult(r)
+	if out.Ip == "" {
+		out.Ip = in.GetIp()
+	}
+	return out, nil
+}
+
+func (s *server) Info(ctx context.Context, _ *InfoRequest) (*InfoResponse, error) {
+	i, err := s.p.Info()
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return newInfoResponse(i), nil
+}
+
+// BatchLookup 每收到一个请求就返回一个结果, 单个IP的错误写在结果中
+func (s *server) BatchLookup(stream grpc.BidiStreamingServer[LookupRequest, BatchLookupResponse]) error {
+	for {
+		in, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		out := &BatchLookupResponse{Ip: in.GetIp()}
+		if r, err := s.p.Search(stream.Context(), in.GetIp(), in.GetLangs()...); err != nil {
+			out.Error, out.ErrorType = err.Error(), ip2region.ErrorType(err)
+		} else {
+			r.IP = in.GetIp()
+			out.Result = newResult(r)
+		}
+
+		if err = stream.Send(out); err != nil {
+			return err
+		}
+	}
+}
+
+// statusError 将查询错误转换为 gRPC 状态, 并在 trailer 中写入错误类型
+func statusError(ctx context.Context, err error) error {
+	grpc.SetTrailer(ctx, metadata.Pairs(errorTypeKey, ip2region.ErrorType(err)))
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, ip2region.ErrInvalidIP):
+		code = codes.InvalidArgument
+	case errors.Is(err, ip2region.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, ip2region.ErrClosed), errors.Is(err, ip2region.ErrDatabaseMissing), errors.Is(err, ip2region.ErrCorruptDatabase), errors.Is(err, ip2region.ErrDownload):
+		code = codes.Unavailable
+	case errors.Is(err, ip2region.ErrReadOnly), errors.Is(err, ip2region.ErrOffline):
+		code = codes.FailedPrecondition
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	}
+	return status.Error(code, err.Error())
+}
+
+// 错误类型对应的错误, 客户端用于还原服务端的错误
+var errorTypes = map[string]error{
+	"invalid_ip":       ip2region.ErrInvalidIP,
+	"not_found":        ip2region.ErrNotFound,
+	"closed":           ip2region.ErrClosed,
+	"corrupt_database": ip2region.ErrCorruptDatabase,
+	"download":         ip2region.ErrDownload,
+	"database_missing": ip2region.ErrDatabaseMissing,
+	"offline":          ip2region.ErrOffline,
+	"read_only":        ip2region.ErrReadOnly,
+}
+
+// RemoteError 服务端返回的错误, 可以用 errors.Is 判断服务端的错误类型, 如 ip2region.ErrNotFound
+type RemoteError struct {
+	Type    string // 同 ip2region.ErrorType
+	Message string
+}
+
+func (e *RemoteError) Error() string { return e.Message }
+
+func (e *RemoteError) Unwrap() error { return errorTypes[e.Type] }
+
+// remoteError 按错误类型还原服务端的错误, 没有错误类型时返回 nil
+func remoteError(typ, msg string) error {
+	if typ == "" {
+		return nil
+	}
+	return &RemoteError{Type: typ, Message: msg}
+}
@@ -0,0 +1,209 @@
+package xdb
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cnk3x/ip2region"
+)
+
+//go:embed dict.csv
+var bundledDict []byte
+
+// 词条层级
+const (
+	LevelCountry     = "country"
+	LevelSubdivision = "subdivision"
+	LevelCity        = "city"
+	LevelISP         = "isp"
+)
+
+// 查找词条时依次尝试去掉的行政区划后缀
+var nameSuffixes = []string{"特别行政区", "维吾尔自治区", "壮族自治区", "回族自治区", "自治区", "自治州", "地区", "省", "市", "盟"}
+
+// Term 词条
+type Term struct {
+	Names map[string]string // 语言代码 -> 名称, 例如 en
+	Code  string            // 代码, 例如国家的 ISO 3166-1 代码
+	ID    uint              // GeoNames ID
+}
+
+// Dictionary 地名词典, 用于将 xdb 中的中文名称翻译为其他语言, 并补充代码
+type Dictionary struct {
+	terms map[string]map[string]*Term // level -> 中文名称 -> 词条
+}
+
+// NewDictionary 创建空词典
+func NewDictionary() *Dictionary {
+	return &Dictionary{terms: map[string]map[string]*Term{}}
+}
+
+// DefaultDictionary 创建包含内置词条的词典
+func DefaultDictionary() *Dictionary {
+	d := NewDictionary()
+	if err := d.Load(bytes.NewReader(bundledDict)); err != nil {
+		panic(fmt.Sprintf("内置词典错误: %v", err))
+	}
+	return d
+}
+
+// LoadFile 从 CSV 文件读取词条, 格式同 Load
+func (d *Dictionary) LoadFile(file string) (err error) {
+	var f *os.File
+	if f, err = os.Open(file); err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = d.Load(f); err != nil {
+		err = fmt.Errorf("词典 %s: %w", file, err)
+	}
+	return
+}
+
+// Load 从 CSV 读取词条并合并到词典中, 第一行为字段名, 必须包含 level 和 zh,
+// code 和 id 为可选字段, 其余字段均视为语言代码, 例如:
+//
+//	level,zh,code,en,ja
+//	country,日本,JP,Japan,日本
+//
+// 已存在的词条会被合并, 非空的字段以新读取的为准。
+func (d *Dictionary) Load(r io.Reader) (err error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	var header []string
+	for {
+		var row []string
+		if row, err = cr.Read(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		line, _ := cr.FieldPos(0)
+
+		if header == nil {
+			for _, h := range row {
+				header = append(header, strings.TrimSpace(h))
+			}
+			continue
+		}
+
+		var level, zh string
+		term := &Term{Names: map[string]string{}}
+		for i, v := range row {
+			if i >= len(header) {
+				break
+			}
+
+			if v = strings.TrimSpace(v); v == "" {
+				continue
+			}
+
+			switch header[i] {
+			case "level":
+				level = v
+			case "zh":
+				zh = v
+			case "code":
+				term.Code = v
+			case "id":
+				var id uint64
+				if id, err = strconv.ParseUint(v, 10, 32); err != nil {
+					return fmt.Errorf("第 %d 行: 无效的 id: %s", line, v)
+				}
+				term.ID = uint(id)
+			default:
+				term.Names[header[i]] = v
+			}
+		}
+
+		if level == "" || zh == "" {
+			return fmt.Errorf("第 %d 行: 缺少 level 或 zh", line)
+		}
+		d.add(level, zh, term)
+	}
+}
+
+func (d *Dictionary) add(level, zh string, term *Term) {
+	terms := d.terms[level]
+	if terms == nil {
+		terms = map[string]*Term{}
+		d.terms[level] = terms
+	}
+
+	old := terms[zh]
+	if old == nil {
+		terms[zh] = term
+		return
+	}
+
+	for lang, name := range term.Names {
+		old.Names[lang] = name
+	}
+	if term.Code != "" {
+		old.Code = term.Code
+	}
+	if term.ID > 0 {
+		old.ID = term.ID
+	}
+}
+
+// Lookup 查找词条, 名称可以带 省, 市, 自治区 等后缀
+func (d *Dictionary) Lookup(level, zh string) *Term {
+	terms := d.terms[level]
+	if terms == nil || zh == "" {
+		return nil
+	}
+
+	if t, ok := terms[zh]; ok {
+		return t
+	}
+
+	for _, suffix := range nameSuffixes {
+		if base, ok := strings.CutSuffix(zh, suffix); ok && base != "" {
+			if t, ok := terms[base]; ok {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// Name 返回 langs 中第一个有翻译的语言的名称, 遇到中文或没有可用翻译时返回原中文名称
+func (d *Dictionary) Name(level, zh string, langs ...string) ip2region.Name {
+	t := d.Lookup(level, zh)
+	if t == nil {
+		return ip2region.NewName(zh, "", 0)
+	}
+	return ip2region.NewName(t.name(zh, langs...), t.Code, t.ID)
+}
+
+func (t *Term) name(zh string, langs ...string) string {
+	for _, lang := range langs {
+		lang = strings.ReplaceAll(lang, "_", "-")
+		if strings.EqualFold(lang, "zh") || strings.HasPrefix(strings.ToLower(lang), "zh-") {
+			return zh
+		}
+
+		if v, ok := t.Names[lang]; ok {
+			return v
+		}
+
+		if base, _, ok := strings.Cut(lang, "-"); ok {
+			if v, ok := t.Names[base]; ok {
+				return v
+			}
+		}
+	}
+	return zh
+}
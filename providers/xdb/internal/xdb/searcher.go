@@ -14,6 +14,7 @@ package xdb
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"os"
 )
 
@@ -73,7 +74,8 @@ func NewHeader(input []byte) (*Header, error) {
 // --- searcher implementation
 
 type Searcher struct {
-	handle *os.File
+	handle io.ReaderAt
+	closer io.Closer
 
 	// header info
 	header  *Header
@@ -108,6 +110,15 @@ func baseNew(dbFile string, vIndex []byte, cBuff []byte) (*Searcher, error) {
 
 	return &Searcher{
 		handle:      handle,
+		closer:      handle,
+		vectorIndex: vIndex,
+	}, nil
+}
+
+// NewWithReaderAt search through r, Close does not close r.
+func NewWithReaderAt(r io.ReaderAt, vIndex []byte) (*Searcher, error) {
+	return &Searcher{
+		handle:      r,
 		vectorIndex: vIndex,
 	}, nil
 }
@@ -125,8 +136,8 @@ func NewWithBuffer(cBuff []byte) (*Searcher, error) {
 }
 
 func (s *Searcher) Close() {
-	if s.handle != nil {
-		err := s.handle.Close()
+	if s.closer != nil {
+		err := s.closer.Close()
 		if err != nil {
 			return
 		}
@@ -150,8 +161,14 @@ func (s *Searcher) SearchByStr(str string) (string, error) {
 
 // Search find the region for the specified long ip
 func (s *Searcher) Search(ip uint32) (string, error) {
-	// reset the global ioCount
-	s.ioCount = 0
+	region, ioCount, err := s.SearchIO(ip)
+	s.ioCount = ioCount
+	return region, err
+}
+
+// SearchIO find the region for the specified long ip and return the io count of this search.
+// Unlike Search it does not touch any shared state, so it is safe for concurrent use.
+func (s *Searcher) SearchIO(ip uint32) (region string, ioCount int, err error) {
 
 	// locate the segment index block based on the vector index
 	var il0 = (ip >> 24) & 0xFF
@@ -162,14 +179,18 @@ func (s *Searcher) Search(ip uint32) (string, error) {
 		sPtr = binary.LittleEndian.Uint32(s.vectorIndex[idx:])
 		ePtr = binary.LittleEndian.Uint32(s.vectorIndex[idx+4:])
 	} else if s.contentBuff != nil {
+		if len(s.contentBuff) < int(HeaderInfoLength+idx+VectorIndexSize) {
+			return "", ioCount, fmt.Errorf("read vector index block at %d: out of range", HeaderInfoLength+idx)
+		}
+
 		sPtr = binary.LittleEndian.Uint32(s.contentBuff[HeaderInfoLength+idx:])
 		ePtr = binary.LittleEndian.Uint32(s.contentBuff[HeaderInfoLength+idx+4:])
 	} else {
 		// read the vector index block
 		var buff = make([]byte, VectorIndexSize)
-		err := s.read(int64(HeaderInfoLength+idx), buff)
+		err := s.read(int64(HeaderInfoLength+idx), buff, &ioCount)
 		if err != nil {
-			return "", fmt.Errorf("read vector index block at %d: %w", HeaderInfoLength+idx, err)
+			return "", ioCount, fmt.Errorf("read vector index block at %d: %w", HeaderInfoLength+idx, err)
 		}
 
 		sPtr = binary.LittleEndian.Uint32(buff)
@@ -185,9 +206,9 @@ func (s *Searcher) Search(ip uint32) (string, error) {
 	for l <= h {
 		m := (l + h) >> 1
 		p := sPtr + uint32(m*SegmentIndexBlockSize)
-		err := s.read(int64(p), buff)
+		err := s.read(int64(p), buff, &ioCount)
 		if err != nil {
-			return "", fmt.Errorf("read segment index at %d: %w", p, err)
+			return "", ioCount, fmt.Errorf("read segment index at %d: %w", p, err)
 		}
 
 		// decode the data step by step to reduce the unnecessary operations
@@ -208,37 +229,35 @@ func (s *Searcher) Search(ip uint32) (string, error) {
 
 	//fmt.Printf("dataLen: %d, dataPtr: %d", dataLen, dataPtr)
 	if dataLen == 0 {
-		return "", nil
+		return "", ioCount, nil
 	}
 
 	// load and return the region data
 	var regionBuff = make([]byte, dataLen)
-	err := s.read(int64(dataPtr), regionBuff)
-	if err != nil {
-		return "", fmt.Errorf("read region at %d: %w", dataPtr, err)
+	if err = s.read(int64(dataPtr), regionBuff, &ioCount); err != nil {
+		return "", ioCount, fmt.Errorf("read region at %d: %w", dataPtr, err)
 	}
 
-	return string(regionBuff), nil
+	return string(regionBuff), ioCount, nil
 }
 
 // do the data read operation based on the setting.
 // content buffer first or will read from the file.
-// this operation will invoke the Seek for file based read.
-func (s *Searcher) read(offset int64, buff []byte) error {
+// file based read uses ReadAt, so concurrent searches don't share the file offset.
+func (s *Searcher) read(offset int64, buff []byte, ioCount *int) error {
 	if s.contentBuff != nil {
+		if offset < 0 || offset > int64(len(s.contentBuff)) {
+			return fmt.Errorf("offset %d out of range: buffer length is %d", offset, len(s.contentBuff))
+		}
+
 		cLen := copy(buff, s.contentBuff[offset:])
 		if cLen != len(buff) {
 			return fmt.Errorf("incomplete read: readed bytes should be %d", len(buff))
 		}
 	} else {
-		_, err := s.handle.Seek(offset, 0)
-		if err != nil {
-			return fmt.Errorf("seek to %d: %w", offset, err)
-		}
-
-		s.ioCount++
-		rLen, err := s.handle.Read(buff)
-		if err != nil {
+		*ioCount++
+		rLen, err := s.handle.ReadAt(buff, offset)
+		if err != nil && !(err == io.EOF && rLen == len(buff)) {
 			return fmt.Errorf("handle read: %w", err)
 		}
 
@@ -10,6 +10,7 @@ package xdb
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -88,9 +89,24 @@ func LoadHeaderFromFile(dbFile string) (*Header, error) {
 	return header, nil
 }
 
+// LoadHeaderFromReaderAt load header info from r
+func LoadHeaderFromReaderAt(r io.ReaderAt) (*Header, error) {
+	var buff = make([]byte, HeaderInfoLength)
+	rLen, err := r.ReadAt(buff, 0)
+	if err != nil && !(err == io.EOF && rLen == len(buff)) {
+		return nil, err
+	}
+
+	return NewHeader(buff)
+}
+
 // LoadHeaderFromBuff wrap the header info from the content buffer
 func LoadHeaderFromBuff(cBuff []byte) (*Header, error) {
-	return NewHeader(cBuff[0:256])
+	if len(cBuff) < HeaderInfoLength {
+		return nil, fmt.Errorf("incomplete header: buffer length %d should be at least %d", len(cBuff), HeaderInfoLength)
+	}
+
+	return NewHeader(cBuff[0:HeaderInfoLength])
 }
 
 // LoadVectorIndex util function to load the vector index from the specified file handle
@@ -114,6 +130,21 @@ func LoadVectorIndex(handle *os.File) ([]byte, error) {
 	return buff, nil
 }
 
+// LoadVectorIndexFromReaderAt load vector index from r
+func LoadVectorIndexFromReaderAt(r io.ReaderAt) ([]byte, error) {
+	var buff = make([]byte, VectorIndexRows*VectorIndexCols*VectorIndexSize)
+	rLen, err := r.ReadAt(buff, HeaderInfoLength)
+	if err != nil && !(err == io.EOF && rLen == len(buff)) {
+		return nil, err
+	}
+
+	if rLen != len(buff) {
+		return nil, fmt.Errorf("incomplete read: readed bytes should be %d", len(buff))
+	}
+
+	return buff, nil
+}
+
 // LoadVectorIndexFromFile load vector index from a specified file path
 func LoadVectorIndexFromFile(dbFile string) ([]byte, error) {
 	handle, err := os.OpenFile(dbFile, os.O_RDONLY, 0600)
@@ -2,10 +2,17 @@ package xdb
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net/netip"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cnk3x/ip2region"
 	"github.com/cnk3x/ip2region/pkg/fileio"
@@ -19,6 +26,9 @@ var (
 
 type CachePolicy string
 
+// ErrInvalidCachePolicy 错误的缓存策略
+var ErrInvalidCachePolicy = errors.New("invalid cache policy")
+
 const (
 	File    CachePolicy = "file"
 	Content CachePolicy = "content"
@@ -26,15 +36,36 @@ const (
 )
 
 type Provider struct {
+	mu     sync.RWMutex
 	xdb    *xdb.Searcher
+	header *xdb.Header
+	stat   fs.FileInfo // 加载时的文件状态, 用于判断是否已被其他进程更新
+
 	policy CachePolicy
 	dbUrl  string
 	dbFile string
+	maxAge time.Duration
+	keep   int
+	stop   context.CancelFunc
+
+	offline bool
+	buf     []byte      // 内存中的地址库, dbFile 不存在时使用
+	ra      io.ReaderAt // 通过 ReadAt 查询的地址库, dbFile 不存在时使用
+	raSize  int64
+	dict    *Dictionary
+	metrics ip2region.Metrics
 }
 
 type Options struct {
 	DownloadUrl string
 	Cache       CachePolicy
+	MaxAge      time.Duration // 地址库最长使用时间, 超过后在打开时自动更新, 0 表示不限制
+	Versions    int           // 保留的地址库版本数, 大于 0 时可通过 Rollback 回滚, 需要系统支持符号链接, 0 表示直接覆盖
+	Watch       time.Duration // 轮询地址库文件的间隔, 文件被替换后自动重新加载, 0 表示不监听
+	Offline     bool          // 离线模式, 不下载地址库, 文件不存在时返回 ip2region.ErrDatabaseMissing
+	UpdateFile  string        // 内存地址库的更新路径, 设置后 Update 下载到该路径, 且该文件存在时优先于内存地址库
+	Dictionary  []string      // 自定义词典文件, 合并到内置词典中, 格式见 Dictionary.Load
+	Metrics     ip2region.Metrics
 }
 
 func Open(ctx context.Context, dbPath string, options *Options) (p ip2region.Provider, err error) {
@@ -46,13 +77,23 @@ func Open(ctx context.Context, dbPath string, options *Options) (p ip2region.Pro
 		options.DownloadUrl = dbDownloadUrl
 	}
 
+	if options.Metrics == nil {
+		options.Metrics = ip2region.NopMetrics{}
+	}
+
 	if options.Cache == "" {
 		options.Cache = File
 	}
 
-	s := &Provider{dbUrl: options.DownloadUrl, dbFile: dbPath, policy: options.Cache}
+	s := &Provider{dbUrl: options.DownloadUrl, dbFile: dbPath, policy: options.Cache, maxAge: options.MaxAge, keep: options.Versions, offline: options.Offline, metrics: options.Metrics}
+	if s.dict, err = loadDictionary(options.Dictionary); err != nil {
+		return
+	}
 
 	if err = fileio.CheckExist(dbPath, func() (err error) {
+		if s.offline {
+			return fmt.Errorf("%w: %s", ip2region.ErrDatabaseMissing, dbPath)
+		}
 		slog.Info("地址库不存在，开始下载", "path", dbPath, "url", options.DownloadUrl)
 		err = s.Update(ctx)
 		return
@@ -60,73 +101,355 @@ func Open(ctx context.Context, dbPath string, options *Options) (p ip2region.Pro
 		return
 	}
 
-	return s, s.init()
+	if !s.loaded() {
+		if err = s.init(); err != nil {
+			return s, err
+		}
+	}
+
+	s.checkAge(ctx)
+
+	s.watch(options.Watch)
+	return s, nil
+}
+
+// OpenBytes 从内存打开地址库, 例如通过 go:embed 嵌入的地址库。
+// 未设置 Options.UpdateFile 时地址库只读, Update 返回 ip2region.ErrReadOnly。
+// Options.Versions 作用于 UpdateFile, 未设置 UpdateFile 时返回错误。
+func OpenBytes(b []byte, options *Options) (p ip2region.Provider, err error) {
+	if options == nil {
+		options = &Options{}
+	}
+	return openSource(&Provider{buf: b, policy: Content}, options)
+}
+
+// OpenReaderAt 从 r 打开大小为 size 的地址库, 其他同 OpenBytes。
+// Options.Cache 为 content 时一次读入内存, 否则每次查询通过 r.ReadAt 读取, index 时预先读取向量索引, 默认 file。
+// 关闭 Provider 时不会关闭 r。
+func OpenReaderAt(r io.ReaderAt, size int64, options *Options) (p ip2region.Provider, err error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	switch options.Cache {
+	case Content:
+		b := make([]byte, size)
+		if _, err = io.ReadFull(io.NewSectionReader(r, 0, size), b); err != nil {
+			return nil, fmt.Errorf("%w: 读取地址库失败: %w", ip2region.ErrCorruptDatabase, err)
+		}
+		return OpenBytes(b, options)
+	case File, Index, "":
+		policy := options.Cache
+		if policy == "" {
+			policy = File
+		}
+		return openSource(&Provider{ra: r, raSize: size, policy: policy}, options)
+	default:
+		return nil, fmt.Errorf("%w: `%s`", ErrInvalidCachePolicy, options.Cache)
+	}
 }
 
+// openSource 打开内存中或通过 io.ReaderAt 读取的地址库 s
+func openSource(s *Provider, options *Options) (p ip2region.Provider, err error) {
+	if options.DownloadUrl == "" {
+		options.DownloadUrl = dbDownloadUrl
+	}
+
+	if options.Metrics == nil {
+		options.Metrics = ip2region.NopMetrics{}
+	}
+
+	if options.Versions > 0 && options.UpdateFile == "" {
+		return nil, errors.New("Options.Versions 需要同时设置 Options.UpdateFile")
+	}
+
+	s.dbUrl, s.dbFile, s.maxAge, s.keep, s.offline, s.metrics = options.DownloadUrl, options.UpdateFile, options.MaxAge, options.Versions, options.Offline, options.Metrics
+	if s.dict, err = loadDictionary(options.Dictionary); err != nil {
+		return
+	}
+	if err = s.init(); err != nil {
+		return
+	}
+
+	s.checkAge(context.Background())
+
+	s.watch(options.Watch)
+	return s, nil
+}
+
+// OpenFS 从 fsys 中打开地址库, 例如 embed.FS, 其他同 OpenBytes
+func OpenFS(fsys fs.FS, name string, options *Options) (p ip2region.Provider, err error) {
+	var b []byte
+	if b, err = fs.ReadFile(fsys, name); err != nil {
+		return
+	}
+	return OpenBytes(b, options)
+}
+
+func loadDictionary(files []string) (d *Dictionary, err error) {
+	d = DefaultDictionary()
+	for _, file := range files {
+		if err = d.LoadFile(file); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// init 打开并校验地址库, 成功后替换当前使用的地址库
 func (d *Provider) init() (err error) {
-	var s *xdb.Searcher
-	switch d.policy {
-	case File:
-		s, err = xdb.NewWithFileOnly(d.dbFile)
-	case Index:
+	var (
+		s      *xdb.Searcher
+		header *xdb.Header
+		stat   fs.FileInfo
+	)
+
+	if s, header, stat, err = d.open(d.dbFile); err != nil {
+		return
+	}
+
+	d.mu.Lock()
+	old := d.xdb
+	d.xdb, d.header, d.stat = s, header, stat
+	d.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return
+}
+
+// watch 每隔 interval 检查地址库文件, 被替换后自动重新加载
+func (d *Provider) watch(interval time.Duration) {
+	if interval <= 0 || d.dbFile == "" {
+		return
+	}
+
+	var ctx context.Context
+	ctx, d.stop = context.WithCancel(context.Background())
+	fileio.Watch(ctx, d.dbFile, interval, d.reload)
+}
+
+// reload 地址库文件被替换后重新加载, 新文件校验失败时继续使用旧地址库
+func (d *Provider) reload() {
+	d.mu.RLock()
+	stat := d.stat
+	d.mu.RUnlock()
+
+	if !fileio.Changed(stat, d.dbFile) {
+		return
+	}
+
+	if err := d.init(); err != nil {
+		slog.Warn("地址库重新加载失败，继续使用旧地址库", "path", d.dbFile, "err", err)
+		return
+	}
+	slog.Info("地址库已重新加载", "path", d.dbFile)
+}
+
+// check 校验下载或回滚的地址库文件, 通过后才替换当前文件, 校验期间继续使用当前地址库
+func (d *Provider) check(path string) (err error) {
+	s, _, _, err := d.open(path)
+	if err == nil {
+		s.Close()
+	}
+	return
+}
+
+// open 按缓存策略打开地址库文件 path 并校验, path 不存在时使用内存中的地址库
+func (d *Provider) open(path string) (s *xdb.Searcher, header *xdb.Header, stat fs.FileInfo, err error) {
+	if path != "" {
+		stat, _ = os.Stat(path)
+	}
+
+	// 错误信息中的地址库文件, 使用内存中的地址库时为空
+	src := path
+	switch {
+	case stat == nil && d.buf != nil:
+		src = ""
+		if header, err = xdb.LoadHeaderFromBuff(d.buf); err == nil {
+			s, err = xdb.NewWithBuffer(d.buf)
+		}
+	case stat == nil && d.ra != nil:
+		src = ""
 		var vi []byte
-		if vi, err = xdb.LoadVectorIndexFromFile(d.dbFile); err == nil {
-			s, err = xdb.NewWithVectorIndex(d.dbFile, vi)
+		if header, err = xdb.LoadHeaderFromReaderAt(d.ra); err == nil && d.policy == Index {
+			vi, err = xdb.LoadVectorIndexFromReaderAt(d.ra)
 		}
-	case Content:
+		if err == nil {
+			s, err = xdb.NewWithReaderAt(d.ra, vi)
+		}
+	case d.policy == File:
+		if header, err = xdb.LoadHeaderFromFile(path); err == nil {
+			s, err = xdb.NewWithFileOnly(path)
+		}
+	case d.policy == Index:
+		var vi []byte
+		if header, err = xdb.LoadHeaderFromFile(path); err == nil {
+			if vi, err = xdb.LoadVectorIndexFromFile(path); err == nil {
+				s, err = xdb.NewWithVectorIndex(path, vi)
+			}
+		}
+	case d.policy == Content:
 		var buf []byte
-		if buf, err = xdb.LoadContentFromFile(d.dbFile); err == nil {
-			s, err = xdb.NewWithBuffer(buf)
+		if buf, err = xdb.LoadContentFromFile(path); err == nil {
+			if header, err = xdb.LoadHeaderFromBuff(buf); err == nil {
+				s, err = xdb.NewWithBuffer(buf)
+			}
 		}
 	default:
-		err = fmt.Errorf("错误的缓存策略 `%s`", d.policy)
+		err = fmt.Errorf("%w: `%s`", ErrInvalidCachePolicy, d.policy)
 	}
 
 	if err != nil {
-		if e := errors.Unwrap(err); e != nil {
-			err = e
+		var pe *fs.PathError
+		switch {
+		case errors.As(err, &pe), errors.Is(err, ErrInvalidCachePolicy):
+		case src != "":
+			err = fmt.Errorf("%w: %s: %w", ip2region.ErrCorruptDatabase, src, err)
+		default:
+			err = fmt.Errorf("%w: %w", ip2region.ErrCorruptDatabase, err)
 		}
 		return
 	}
 
-	d.xdb = s
+	if err = validate(s, header); err != nil {
+		s.Close()
+		s = nil
+		if src != "" {
+			err = fmt.Errorf("%s: %w", src, err)
+		}
+	}
+	return
+}
+
+// validate 校验地址库头信息, 并试查询一次
+func validate(s *xdb.Searcher, h *xdb.Header) (err error) {
+	if h.IndexPolicy != xdb.VectorIndexPolicy && h.IndexPolicy != xdb.BTreeIndexPolicy || h.EndIndexPtr < h.StartIndexPtr {
+		return fmt.Errorf("%w: 无效的地址库头信息: %+v", ip2region.ErrCorruptDatabase, *h)
+	}
+
+	if _, err = s.Search(0x01010101); err != nil {
+		err = fmt.Errorf("%w: 地址库校验失败: %w", ip2region.ErrCorruptDatabase, err)
+	}
 	return
 }
 
+func (d *Provider) loaded() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.xdb != nil
+}
+
 func (d *Provider) Update(ctx context.Context) (err error) {
-	return httpio.New(d.dbUrl).Use(httpio.StatusOK, httpio.Progress(consoleProgress)).
+	defer func(start time.Time) { d.metrics.ObserveUpdate("xdb", time.Since(start), err) }(time.Now())
+
+	if d.dbFile == "" {
+		return ip2region.ErrReadOnly
+	}
+
+	if d.offline {
+		return ip2region.ErrOffline
+	}
+
+	var unlock func() error
+	if unlock, err = fileio.LockFile(ctx, d.dbFile); err != nil {
+		return
+	}
+	defer unlock()
+
+	d.mu.RLock()
+	stat := d.stat
+	d.mu.RUnlock()
+
+	// 等待锁期间其他进程可能已完成更新, 直接重新加载
+	if fileio.Changed(stat, d.dbFile) {
+		slog.Info("地址库已被其他进程更新，重新加载", "path", d.dbFile)
+		return d.init()
+	}
+
+	err = httpio.New(d.dbUrl).Use(httpio.StatusOK, httpio.Progress(consoleProgress)).
 		Do(ctx, httpio.Download(
 			d.dbFile,
 			fileio.UseTempFile,
 			fileio.Overwrite,
-			fileio.BeforeSave(d.Close),
+			fileio.KeepVersions(d.keep),
+			fileio.Validate(d.check),
 			fileio.AfterSave(d.init),
 		))
+	return ip2region.NewDownloadError(d.dbUrl, err)
+}
+
+// Rollback 切换到上一个版本的地址库并重新加载
+func (d *Provider) Rollback(ctx context.Context) (err error) {
+	if d.dbFile == "" {
+		return ip2region.ErrReadOnly
+	}
+
+	var unlock func() error
+	if unlock, err = fileio.LockFile(ctx, d.dbFile); err != nil {
+		return
+	}
+	defer unlock()
+
+	var prev fileio.Version
+	if prev, err = fileio.Rollback(d.dbFile, fileio.Validate(d.check), fileio.AfterSave(d.init)); err != nil {
+		return
+	}
+
+	slog.Info("地址库已回滚", "path", d.dbFile, "version", prev.Name)
+	return
 }
 
-func (d *Provider) Search(_ context.Context, ip string, _ ...string) (result *ip2region.Result, err error) {
+// Search 查询IP地址, langs 指定结果的语言, 例如 en, 未指定或为中文时返回中文名称
+func (d *Provider) Search(_ context.Context, ip string, langs ...string) (result *ip2region.Result, err error) {
+	defer func(start time.Time) { d.metrics.ObserveLookup("xdb", time.Since(start), err) }(time.Now())
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var addr netip.Addr
+	if addr, err = ip2region.ParseAddr(ip); err != nil {
+		return
+	}
+
+	if result = ip2region.ReservedResult(addr); result != nil {
+		return
+	}
+
 	if d.xdb == nil {
-		err = errors.New("reader is nil")
+		err = ip2region.ErrClosed
 		return
 	}
 
-	var ipInt uint32
-	if ipInt, err = xdb.IP2Int(ip); err != nil {
+	if !addr.Is4() {
+		err = fmt.Errorf("%w: xdb 不支持 IPv6 地址 %s", ip2region.ErrNotFound, ip)
 		return
 	}
-	return d.search(ipInt)
+
+	b := addr.As4()
+	return d.search(binary.BigEndian.Uint32(b[:]), langs...)
 }
 
-func (d *Provider) search(ip uint32) (result *ip2region.Result, err error) {
-	var r string
-	if r, err = d.xdb.Search(ip); err != nil {
+func (d *Provider) search(ip uint32, langs ...string) (result *ip2region.Result, err error) {
+	r, reads, err := d.xdb.SearchIO(ip)
+	if d.policy != Content {
+		d.metrics.ObserveIO("xdb", reads)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ip2region.ErrCorruptDatabase, err)
+		return
+	}
+
+	if r == "" {
+		err = fmt.Errorf("%w: %s", ip2region.ErrNotFound, xdb.Long2IP(ip))
 		return
 	}
 
 	// 国家|0|省/州|城市|网络运营商
 	rs := strings.SplitN(r, "|", 5)
 	if len(rs) != 5 {
-		err = fmt.Errorf("无效查询结果: %s", r)
+		err = fmt.Errorf("%w: 无效查询结果: %s", ip2region.ErrCorruptDatabase, r)
 		return
 	}
 
@@ -140,19 +463,87 @@ func (d *Provider) search(ip uint32) (result *ip2region.Result, err error) {
 
 	result = &ip2region.Result{
 		IP:          xdb.Long2IP(ip),
-		Country:     ip2region.NewName(rs[0], "", 0),
+		Country:     d.dict.Name(LevelCountry, rs[0], langs...),
 		Continent:   ip2region.NewName(rs[1], "", 0),
-		Subdivision: ip2region.NewName(rs[2], "", 0),
-		City:        ip2region.NewName(rs[3], "", 0),
-		ISP:         rs[4],
+		Subdivision: d.dict.Name(LevelSubdivision, rs[2], langs...),
+		City:        d.dict.Name(LevelCity, rs[3], langs...),
+		ISP:         d.dict.Name(LevelISP, rs[4], langs...).Name,
+		Kind:        ip2region.KindPublic,
 	}
 	return
 }
 
+func (d *Provider) Info() (info *ip2region.Info, err error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.xdb == nil || d.header == nil {
+		err = ip2region.ErrClosed
+		return
+	}
+
+	h := d.header
+	info = &ip2region.Info{
+		Type:      "xdb",
+		File:      d.dbFile,
+		BuildTime: time.Unix(int64(h.CreatedAt), 0),
+		Meta: map[string]any{
+			"version":         h.Version,
+			"index_policy":    h.IndexPolicy.String(),
+			"cache_policy":    string(d.policy),
+			"start_index_ptr": h.StartIndexPtr,
+			"end_index_ptr":   h.EndIndexPtr,
+			"segments":        (h.EndIndexPtr-h.StartIndexPtr)/xdb.SegmentIndexBlockSize + 1,
+		},
+	}
+	info.Stale = info.Expired(d.maxAge)
+
+	if d.stat == nil && d.buf != nil {
+		info.File = ""
+		info.Size = int64(len(d.buf))
+	} else if d.stat == nil && d.ra != nil {
+		info.File = ""
+		info.Size = d.raSize
+	} else if stat, e := os.Stat(d.dbFile); e == nil {
+		info.Size = stat.Size()
+		info.ModTime = stat.ModTime()
+	}
+	return
+}
+
+// checkAge 地址库超过 maxAge 时自动更新, 更新失败时继续使用旧地址库
+func (d *Provider) checkAge(ctx context.Context) {
+	info, err := d.Info()
+	if err != nil || !info.Stale {
+		return
+	}
+
+	if d.offline || d.dbFile == "" {
+		slog.Warn("地址库已过期", "path", d.dbFile, "build_time", info.BuildTime, "max_age", d.maxAge)
+		return
+	}
+
+	slog.Warn("地址库已过期，开始更新", "path", d.dbFile, "build_time", info.BuildTime, "max_age", d.maxAge)
+	if err = d.Update(ctx); err != nil {
+		slog.Warn("地址库更新失败，继续使用旧地址库", "path", d.dbFile, "err", err)
+	}
+}
+
 func (d *Provider) Close() (err error) {
+	if d.stop != nil {
+		d.stop()
+	}
+	return d.close()
+}
+
+func (d *Provider) close() (err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	if d.xdb != nil {
 		d.xdb.Close()
 		d.xdb = nil
+		d.header = nil
 	}
 	return
 }
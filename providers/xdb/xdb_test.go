@@ -0,0 +1,222 @@
+package xdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnk3x/ip2region"
+	"github.com/cnk3x/ip2region/pkg/fileio"
+	"github.com/cnk3x/ip2region/pkg/httpio"
+	"github.com/cnk3x/ip2region/providers/xdb/internal/xdb"
+)
+
+// 测试地址库中所有 IPv4 地址的区域
+const testRegion = "中国|0|广东省|深圳市|电信"
+
+// vectorIndexEnd 向量索引结束的位置, 之后为区域数据和段索引
+const vectorIndexEnd = xdb.HeaderInfoLength + xdb.VectorIndexRows*xdb.VectorIndexCols*xdb.VectorIndexSize
+
+// buildXDB 生成只有一个段(0.0.0.0-255.255.255.255)的地址库
+func buildXDB() []byte {
+	region := []byte(testRegion)
+	dataPtr := uint32(vectorIndexEnd)
+	segPtr := dataPtr + uint32(len(region))
+
+	b := make([]byte, int(segPtr)+xdb.SegmentIndexBlockSize)
+	binary.LittleEndian.PutUint16(b[0:], 2)
+	binary.LittleEndian.PutUint16(b[2:], uint16(xdb.VectorIndexPolicy))
+	binary.LittleEndian.PutUint32(b[4:], 1700000000)
+	binary.LittleEndian.PutUint32(b[8:], segPtr)
+	binary.LittleEndian.PutUint32(b[12:], segPtr)
+
+	for i := xdb.HeaderInfoLength; i < vectorIndexEnd; i += xdb.VectorIndexSize {
+		binary.LittleEndian.PutUint32(b[i:], segPtr)
+		binary.LittleEndian.PutUint32(b[i+4:], segPtr)
+	}
+
+	copy(b[dataPtr:], region)
+
+	seg := b[segPtr:]
+	binary.LittleEndian.PutUint32(seg[0:], 0)
+	binary.LittleEndian.PutUint32(seg[4:], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint16(seg[8:], uint16(len(region)))
+	binary.LittleEndian.PutUint32(seg[10:], dataPtr)
+	return b
+}
+
+func TestOpenCorruptFile(t *testing.T) {
+	full := buildXDB()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"22 bytes", full[:22]},
+		{"header only", full[:xdb.HeaderInfoLength]},
+		{"truncated in vector index", full[:xdb.HeaderInfoLength+1000]},
+		{"truncated after vector index", full[:vectorIndexEnd]},
+		{"truncated in segment index", full[:len(full)-4]},
+	}
+
+	for _, tt := range tests {
+		for _, policy := range []CachePolicy{File, Index, Content} {
+			t.Run(tt.name+"/"+string(policy), func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "ip2region.xdb")
+				if err := os.WriteFile(path, tt.data, 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				p, err := Open(context.Background(), path, &Options{Offline: true, Cache: policy})
+				if p != nil {
+					p.Close()
+				}
+				if !errors.Is(err, ip2region.ErrCorruptDatabase) {
+					t.Errorf("Open() err = %v, want %v", err, ip2region.ErrCorruptDatabase)
+				}
+			})
+		}
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip2region.xdb")
+	if err := os.WriteFile(path, buildXDB(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []CachePolicy{File, Index, Content} {
+		t.Run(string(policy), func(t *testing.T) {
+			p, err := Open(context.Background(), path, &Options{Offline: true, Cache: policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			r, err := p.Search(context.Background(), "8.8.8.8")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Country.Code != "CN" || r.City.Name != "深圳市" || r.ISP != "电信" {
+				t.Errorf("Search() = %+v", r)
+			}
+		})
+	}
+}
+
+func TestOpenCorruptBytes(t *testing.T) {
+	full := buildXDB()
+	for _, n := range []int{0, 22, xdb.HeaderInfoLength, xdb.HeaderInfoLength + 1000, vectorIndexEnd, len(full) - 4} {
+		t.Run(fmt.Sprintf("OpenBytes/%d", n), func(t *testing.T) {
+			if _, err := OpenBytes(full[:n], nil); !errors.Is(err, ip2region.ErrCorruptDatabase) {
+				t.Errorf("OpenBytes() err = %v, want %v", err, ip2region.ErrCorruptDatabase)
+			}
+		})
+
+		for _, policy := range []CachePolicy{File, Index, Content} {
+			t.Run(fmt.Sprintf("OpenReaderAt/%d/%s", n, policy), func(t *testing.T) {
+				_, err := OpenReaderAt(bytes.NewReader(full[:n]), int64(n), &Options{Cache: policy})
+				if !errors.Is(err, ip2region.ErrCorruptDatabase) {
+					t.Errorf("OpenReaderAt() err = %v, want %v", err, ip2region.ErrCorruptDatabase)
+				}
+			})
+		}
+	}
+}
+
+func TestOpenBytes(t *testing.T) {
+	p, err := OpenBytes(buildXDB(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if r, err := p.Search(context.Background(), "1.2.3.4"); err != nil || r.Subdivision.Name != "广东省" {
+		t.Errorf("Search() = %+v, %v", r, err)
+	}
+}
+
+func TestOpenErrorContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ip2region.xdb")
+	if err := os.WriteFile(path, buildXDB()[:22], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 错误包装哨兵错误, 同时保留文件路径和缓存策略
+	_, err := Open(context.Background(), path, &Options{Offline: true, Cache: "memory"})
+	if !errors.Is(err, ErrInvalidCachePolicy) || !strings.Contains(err.Error(), "memory") {
+		t.Errorf("Open() err = %v, want %v with policy name", err, ErrInvalidCachePolicy)
+	}
+
+	for _, policy := range []CachePolicy{File, Content} {
+		_, err = Open(context.Background(), path, &Options{Offline: true, Cache: policy})
+		if !errors.Is(err, ip2region.ErrCorruptDatabase) || !strings.Contains(err.Error(), path) {
+			t.Errorf("Open(%s) err = %v, want %v with path", policy, err, ip2region.ErrCorruptDatabase)
+		}
+	}
+
+	_, err = Open(context.Background(), filepath.Join(t.TempDir(), "missing", "ip2region.xdb"), &Options{Offline: true})
+	if !errors.Is(err, ip2region.ErrDatabaseMissing) {
+		t.Errorf("Open(missing) err = %v, want %v", err, ip2region.ErrDatabaseMissing)
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	full := buildXDB()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok.xdb":
+			w.Write(full)
+		case "/corrupt.xdb":
+			w.Write(full[:vectorIndexEnd])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		want []error
+	}{
+		{"not found", srv.URL + "/missing.xdb", []error{ip2region.ErrDownload, httpio.ErrStatus}},
+		{"corrupt", srv.URL + "/corrupt.xdb", []error{ip2region.ErrDownload, fileio.ErrValidate, ip2region.ErrCorruptDatabase}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ip2region.xdb")
+			p, err := Open(context.Background(), path, &Options{DownloadUrl: srv.URL + "/ok.xdb"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			p.(*Provider).dbUrl = tt.url
+			err = p.Update(context.Background())
+			for _, want := range tt.want {
+				if !errors.Is(err, want) {
+					t.Errorf("Update() err = %v, want %v", err, want)
+				}
+			}
+
+			var de *ip2region.DownloadError
+			if !errors.As(err, &de) || de.URL != tt.url {
+				t.Errorf("Update() err = %v, want DownloadError for %s", err, tt.url)
+			}
+
+			// 更新失败后继续使用原来的地址库
+			if _, err = p.Search(context.Background(), "1.2.3.4"); err != nil {
+				t.Errorf("Search() after failed update err = %v", err)
+			}
+		})
+	}
+}
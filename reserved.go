@@ -0,0 +1,104 @@
+package ip2region
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Kind 地址类型
+type Kind string
+
+const (
+	KindPublic        Kind = "public"        // 公网地址
+	KindPrivate       Kind = "private"       // 私有地址, RFC 1918
+	KindLoopback      Kind = "loopback"      // 环回地址, RFC 1122, RFC 4291
+	KindLinkLocal     Kind = "link-local"    // 链路本地地址, RFC 3927, RFC 4291
+	KindCGNAT         Kind = "cgnat"         // 运营商级NAT共享地址, RFC 6598
+	KindDocumentation Kind = "documentation" // 文档示例地址, RFC 5737, RFC 3849, RFC 9637
+	KindBenchmarking  Kind = "benchmarking"  // 基准测试地址, RFC 2544
+	KindMulticast     Kind = "multicast"     // 组播地址, RFC 5771, RFC 4291
+	KindUniqueLocal   Kind = "unique-local"  // 唯一本地地址, RFC 4193
+	KindUnspecified   Kind = "unspecified"   // 未指定地址
+	KindBroadcast     Kind = "broadcast"     // 受限广播地址, RFC 919
+	KindNAT64         Kind = "nat64"         // IPv4/IPv6 转换地址, RFC 6052, RFC 8215
+	Kind6to4          Kind = "6to4"          // 6to4 地址, RFC 3056
+	KindReserved      Kind = "reserved"      // 其他保留地址
+)
+
+// Reserved 是否为特殊用途地址的类型, 即 KindPublic 以外的内置类型
+func (k Kind) Reserved() bool {
+	switch k {
+	case KindPrivate, KindLoopback, KindLinkLocal, KindCGNAT, KindDocumentation, KindBenchmarking,
+		KindMulticast, KindUniqueLocal, KindUnspecified, KindBroadcast, KindNAT64, Kind6to4, KindReserved:
+		return true
+	}
+	return false
+}
+
+// 特殊用途地址段, 参考 IANA IPv4/IPv6 Special-Purpose Address Registry, 更具体的网段在前
+var reservedPrefixes = []struct {
+	prefix netip.Prefix
+	kind   Kind
+}{
+	{netip.MustParsePrefix("0.0.0.0/32"), KindUnspecified},
+	{netip.MustParsePrefix("0.0.0.0/8"), KindReserved},
+	{netip.MustParsePrefix("10.0.0.0/8"), KindPrivate},
+	{netip.MustParsePrefix("100.64.0.0/10"), KindCGNAT},
+	{netip.MustParsePrefix("127.0.0.0/8"), KindLoopback},
+	{netip.MustParsePrefix("169.254.0.0/16"), KindLinkLocal},
+	{netip.MustParsePrefix("172.16.0.0/12"), KindPrivate},
+	{netip.MustParsePrefix("192.0.0.0/24"), KindReserved},
+	{netip.MustParsePrefix("192.0.2.0/24"), KindDocumentation},
+	{netip.MustParsePrefix("192.88.99.0/24"), KindReserved},
+	{netip.MustParsePrefix("192.168.0.0/16"), KindPrivate},
+	{netip.MustParsePrefix("198.18.0.0/15"), KindBenchmarking},
+	{netip.MustParsePrefix("198.51.100.0/24"), KindDocumentation},
+	{netip.MustParsePrefix("203.0.113.0/24"), KindDocumentation},
+	{netip.MustParsePrefix("224.0.0.0/4"), KindMulticast},
+	{netip.MustParsePrefix("255.255.255.255/32"), KindBroadcast},
+	{netip.MustParsePrefix("240.0.0.0/4"), KindReserved},
+
+	{netip.MustParsePrefix("::/128"), KindUnspecified},
+	{netip.MustParsePrefix("::1/128"), KindLoopback},
+	{netip.MustParsePrefix("64:ff9b::/96"), KindNAT64},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), KindNAT64},
+	{netip.MustParsePrefix("100::/64"), KindReserved},
+	{netip.MustParsePrefix("2001::/23"), KindReserved},
+	{netip.MustParsePrefix("2001:db8::/32"), KindDocumentation},
+	{netip.MustParsePrefix("2002::/16"), Kind6to4},
+	{netip.MustParsePrefix("3fff::/20"), KindDocumentation},
+	{netip.MustParsePrefix("fc00::/7"), KindUniqueLocal},
+	{netip.MustParsePrefix("fe80::/10"), KindLinkLocal},
+	{netip.MustParsePrefix("ff00::/8"), KindMulticast},
+}
+
+// ParseAddr 解析IP地址, IPv4 映射的 IPv6 地址会转换为 IPv4, 无效时返回 ErrInvalidIP
+func ParseAddr(ip string) (addr netip.Addr, err error) {
+	if addr, err = netip.ParseAddr(strings.TrimSpace(ip)); err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidIP, ip)
+		return
+	}
+	addr = addr.Unmap().WithZone("")
+	return
+}
+
+// Classify 返回地址类型, 不属于任何特殊用途地址段时返回 KindPublic
+func Classify(addr netip.Addr) Kind {
+	addr = addr.Unmap()
+	for _, r := range reservedPrefixes {
+		if r.prefix.Contains(addr) {
+			return r.kind
+		}
+	}
+	return KindPublic
+}
+
+// ReservedResult 地址属于特殊用途地址段时返回对应的查询结果, 否则返回 nil。
+// 各 Provider 在查询地址库前调用, 保证特殊地址的查询结果一致。
+func ReservedResult(addr netip.Addr) *Result {
+	if kind := Classify(addr); kind != KindPublic {
+		return &Result{IP: addr.String(), Reserved: true, Kind: kind}
+	}
+	return nil
+}
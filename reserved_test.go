@@ -0,0 +1,183 @@
+package ip2region
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want Kind
+	}{
+		// 0.0.0.0/32, 0.0.0.0/8
+		{"0.0.0.0", KindUnspecified},
+		{"0.0.0.1", KindReserved},
+		{"0.255.255.255", KindReserved},
+		{"1.0.0.0", KindPublic},
+		// 10.0.0.0/8
+		{"9.255.255.255", KindPublic},
+		{"10.0.0.0", KindPrivate},
+		{"10.255.255.255", KindPrivate},
+		{"11.0.0.0", KindPublic},
+		// 100.64.0.0/10
+		{"100.63.255.255", KindPublic},
+		{"100.64.0.0", KindCGNAT},
+		{"100.127.255.255", KindCGNAT},
+		{"100.128.0.0", KindPublic},
+		// 127.0.0.0/8
+		{"126.255.255.255", KindPublic},
+		{"127.0.0.0", KindLoopback},
+		{"127.255.255.255", KindLoopback},
+		{"128.0.0.0", KindPublic},
+		// 169.254.0.0/16
+		{"169.253.255.255", KindPublic},
+		{"169.254.0.0", KindLinkLocal},
+		{"169.254.255.255", KindLinkLocal},
+		{"169.255.0.0", KindPublic},
+		// 172.16.0.0/12
+		{"172.15.255.255", KindPublic},
+		{"172.16.0.0", KindPrivate},
+		{"172.31.255.255", KindPrivate},
+		{"172.32.0.0", KindPublic},
+		// 192.0.0.0/24, 192.0.2.0/24
+		{"191.255.255.255", KindPublic},
+		{"192.0.0.0", KindReserved},
+		{"192.0.0.255", KindReserved},
+		{"192.0.1.0", KindPublic},
+		{"192.0.1.255", KindPublic},
+		{"192.0.2.0", KindDocumentation},
+		{"192.0.2.255", KindDocumentation},
+		{"192.0.3.0", KindPublic},
+		// 192.88.99.0/24
+		{"192.88.98.255", KindPublic},
+		{"192.88.99.0", KindReserved},
+		{"192.88.99.255", KindReserved},
+		{"192.88.100.0", KindPublic},
+		// 192.168.0.0/16
+		{"192.167.255.255", KindPublic},
+		{"192.168.0.0", KindPrivate},
+		{"192.168.255.255", KindPrivate},
+		{"192.169.0.0", KindPublic},
+		// 198.18.0.0/15
+		{"198.17.255.255", KindPublic},
+		{"198.18.0.0", KindBenchmarking},
+		{"198.19.255.255", KindBenchmarking},
+		{"198.20.0.0", KindPublic},
+		// 198.51.100.0/24
+		{"198.51.99.255", KindPublic},
+		{"198.51.100.0", KindDocumentation},
+		{"198.51.100.255", KindDocumentation},
+		{"198.51.101.0", KindPublic},
+		// 203.0.113.0/24
+		{"203.0.112.255", KindPublic},
+		{"203.0.113.0", KindDocumentation},
+		{"203.0.113.255", KindDocumentation},
+		{"203.0.114.0", KindPublic},
+		// 224.0.0.0/4, 240.0.0.0/4, 255.255.255.255/32
+		{"223.255.255.255", KindPublic},
+		{"224.0.0.0", KindMulticast},
+		{"239.255.255.255", KindMulticast},
+		{"240.0.0.0", KindReserved},
+		{"255.255.255.254", KindReserved},
+		{"255.255.255.255", KindBroadcast},
+		// IPv4 映射的 IPv6 地址按 IPv4 分类
+		{"::ffff:10.0.0.1", KindPrivate},
+		{"::ffff:8.8.8.8", KindPublic},
+
+		// ::/128, ::1/128
+		{"::", KindUnspecified},
+		{"::1", KindLoopback},
+		// 64:ff9b::/96
+		{"64:ff9a:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"64:ff9b::", KindNAT64},
+		{"64:ff9b::ffff:ffff", KindNAT64},
+		{"64:ff9b::1:0:0", KindPublic},
+		// 64:ff9b:1::/48
+		{"64:ff9b:0:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"64:ff9b:1::", KindNAT64},
+		{"64:ff9b:1:ffff:ffff:ffff:ffff:ffff", KindNAT64},
+		{"64:ff9b:2::", KindPublic},
+		// 100::/64
+		{"ff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"100::", KindReserved},
+		{"100::ffff:ffff:ffff:ffff", KindReserved},
+		{"100:0:0:1::", KindPublic},
+		// 2001::/23
+		{"2000:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"2001::", KindReserved},
+		{"2001:1ff:ffff:ffff:ffff:ffff:ffff:ffff", KindReserved},
+		{"2001:200::", KindPublic},
+		// 2001:db8::/32
+		{"2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"2001:db8::", KindDocumentation},
+		{"2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", KindDocumentation},
+		{"2001:db9::", KindPublic},
+		// 2002::/16
+		{"2001:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"2002::", Kind6to4},
+		{"2002:ffff:ffff:ffff:ffff:ffff:ffff:ffff", Kind6to4},
+		{"2003::", KindPublic},
+		// 3fff::/20
+		{"3ffe:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"3fff::", KindDocumentation},
+		{"3fff:fff:ffff:ffff:ffff:ffff:ffff:ffff", KindDocumentation},
+		{"3fff:1000::", KindPublic},
+		// fc00::/7
+		{"fbff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"fc00::", KindUniqueLocal},
+		{"fdff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindUniqueLocal},
+		// fe80::/10
+		{"fe7f:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindPublic},
+		{"fe80::", KindLinkLocal},
+		{"febf:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindLinkLocal},
+		{"fec0::", KindPublic},
+		// ff00::/8
+		{"ff00::", KindMulticast},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", KindMulticast},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(netip.MustParseAddr(tt.ip)); got != tt.want {
+			t.Errorf("Classify(%s) = %s, want %s", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestReservedPrefixes(t *testing.T) {
+	// 每个网段的首尾地址都应归为该网段的类型, 防止顺序错误时被其他网段覆盖,
+	// covered 为被更具体的网段覆盖的首尾地址
+	covered := map[netip.Addr]bool{
+		netip.MustParseAddr("0.0.0.0"):         true,
+		netip.MustParseAddr("255.255.255.255"): true,
+	}
+
+	for _, r := range reservedPrefixes {
+		first := r.prefix.Masked().Addr()
+		b := first.As16()
+		for i := range first.BitLen() - r.prefix.Bits() {
+			b[15-i/8] |= 1 << (i % 8)
+		}
+		last := netip.AddrFrom16(b)
+		if first.Is4() {
+			last = last.Unmap()
+		}
+
+		for _, addr := range []netip.Addr{first, last} {
+			if got := Classify(addr); got != r.kind && !(covered[addr] && r.prefix.Bits() < addr.BitLen()) {
+				t.Errorf("Classify(%s) in %s = %s, want %s", addr, r.prefix, got, r.kind)
+			}
+		}
+
+		if !r.kind.Reserved() {
+			t.Errorf("%s kind %s should be reserved", r.prefix, r.kind)
+		}
+	}
+
+	if ReservedResult(netip.MustParseAddr("8.8.8.8")) != nil {
+		t.Errorf("ReservedResult(8.8.8.8) should be nil")
+	}
+	if r := ReservedResult(netip.MustParseAddr("64:ff9b::808:808")); r == nil || r.Kind != KindNAT64 || !r.Reserved {
+		t.Errorf("ReservedResult(64:ff9b::808:808) = %+v", r)
+	}
+}